@@ -0,0 +1,133 @@
+// Description: This file implements the "ask" command, which answers a question about the project with citations.
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/vossenwout/crev/internal/ask"
+	"github.com/vossenwout/crev/internal/review"
+	"github.com/vossenwout/crev/internal/tokens"
+	"github.com/vossenwout/crev/pkg/bundle"
+	reviewapi "github.com/vossenwout/crev/pkg/review"
+)
+
+// askCmd represents the ask command
+var askCmd = &cobra.Command{
+	Use:   "ask <question>",
+	Short: "Ask a question about your project",
+	Long: `Ask a one-off question about your project, like how a request is authenticated. The project in the current
+directory is bundled with the filters and redaction of the crev bundle command, with numbered lines, and sent
+with the question to the provider selected in your .crev-config.yaml or with --provider, which must support
+chat (openai, anthropic or ollama).
+
+The answer cites the files and lines it is based on, which are listed as sources below it. Projects of more than
+--max_tokens estimated tokens are not sent, narrow them down with include-ext or ignore-pre in your config.
+
+Before anything is uploaded a summary of the bundle is shown and you are asked for confirmation.
+Use --yes to skip the confirmation, unless require-confirmation is set in your .crev-config.yaml.
+
+Example usage:
+crev ask "how is the review request authenticated?"
+crev ask --provider=anthropic "where are the bundle filters applied?"
+crev ask --yes --stream=false "which commands read crev-project.txt?"
+`,
+	Args: cobra.MinimumNArgs(1),
+	PreRun: func(cmd *cobra.Command, args []string) {
+		bindProviderFlags(cmd, args)
+		err := viper.BindPFlag("stream", cmd.Flags().Lookup("stream"))
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		question := strings.Join(args, " ")
+		provider, err := newProvider("")
+		if err != nil {
+			log.Fatal(err)
+		}
+		chatProvider, ok := provider.(reviewapi.ChatProvider)
+		if !ok {
+			log.Fatalf("The %s provider does not support questions, select openai, anthropic or ollama with --provider", provider.Name())
+		}
+
+		opts, err := bundleOptions()
+		if err != nil {
+			log.Fatal(err)
+		}
+		projectBundle, err := bundle.New(append(opts, bundle.WithLineNumbers())...).Bundle(context.Background())
+		if projectBundle != nil {
+			for _, finding := range projectBundle.Secrets {
+				log.Printf("Redacted %s in %s:%d", finding.Kind, finding.Path, finding.Line)
+			}
+		}
+		if errors.Is(err, bundle.ErrSecretsFound) {
+			log.Fatalf("Found %d secrets, nothing was sent because fail-on-secrets is set", len(projectBundle.Secrets))
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		code := projectBundle.String()
+		if _, maxTokens := tokens.Range(code); viper.GetInt("max_tokens") > 0 && maxTokens > viper.GetInt("max_tokens") {
+			log.Fatalf("The project is estimated at up to %d tokens, more than --max_tokens=%d. Narrow it down with include-ext or ignore-pre in your .crev-config.yaml, or raise --max_tokens.",
+				maxTokens, viper.GetInt("max_tokens"))
+		}
+
+		fmt.Print(review.NewManifest(code, provider.Destination()))
+		if !skipConfirmation() {
+			if !review.IsInteractive() {
+				log.Fatal("Refusing to send code without confirmation. Run with --yes to confirm non-interactively.")
+			}
+			if !review.Confirm("Send this code with your question?", os.Stdin, os.Stdout) {
+				log.Fatal("Question cancelled, nothing was sent.")
+			}
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		fmt.Println()
+		var w io.Writer
+		if viper.GetBool("stream") {
+			w = os.Stdout
+		}
+		answer, err := chatProvider.Chat(ctx, ask.Prompt(code), []reviewapi.Message{{Role: "user", Content: question}}, w)
+		if err != nil {
+			log.Printf("Failed to answer the question: %v", err)
+			os.Exit(review.ExitCode(err))
+		}
+		if w == nil {
+			fmt.Print(answer)
+		}
+		fmt.Println()
+
+		citations, invalid := ask.Citations(answer, reviewapi.LineCounts(code))
+		if len(citations) > 0 {
+			fmt.Println("\nSources:")
+			for _, citation := range citations {
+				fmt.Println("  " + citation.String())
+			}
+		}
+		for _, citation := range invalid {
+			log.Printf("The answer cites %s, which is not part of the project", citation)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(askCmd)
+	addProviderFlags(askCmd)
+	askCmd.Flags().Bool("stream", true, "Show the answer in the terminal while it is written")
+	askCmd.Flags().Int("max_tokens", 100000, "Do not send projects of more estimated tokens, 0 disables the limit")
+	err := viper.BindPFlag("max_tokens", askCmd.Flags().Lookup("max_tokens"))
+	if err != nil {
+		log.Fatal(err)
+	}
+}
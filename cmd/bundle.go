@@ -2,87 +2,31 @@
 package cmd
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"maps"
+	"slices"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"github.com/vossenwout/crev/internal/files"
-	"github.com/vossenwout/crev/internal/formatting"
+	"github.com/vossenwout/crev/internal/redaction"
+	"github.com/vossenwout/crev/internal/tokens"
+	"github.com/vossenwout/crev/pkg/bundle"
 )
 
-var standardPrefixesToIgnore = []string{
-	// ignore .git, .idea, .vscode, etc.
-	".",
-	// ignore crev specific files
-	"crev",
-	// ignore go.mod, go.sum, etc.
-	"go",
-	"license",
-	// readme
-	"readme",
-	"README",
-	// poetry
-	"pyproject.toml",
-	"poetry.lock",
-	"venv",
-	// output files
-	"build",
-	"dist",
-	"out",
-	"target",
-	"bin",
-	// javascript
-	"node_modules",
-	"coverage",
-	"public",
-	"static",
-	"Thumbs.db",
-	"package",
-	"yarn.lock",
-	"package",
-	"tsconfig",
-	// next.js
-	"next.config",
-	"next-env",
-	// python
-	"__pycache__",
-	"logs",
-	// java
-	"gradle",
-	// c++
-	"CMakeLists",
-	// ruby
-	"vendor",
-	"Gemfile",
-	// php
-	"composer",
-	// tailwind
-	"tailwind",
-	"postcss",
-}
-
-var standardExtensionsToIgnore = []string{
-	".jpeg",
-	".jpg",
-	".png",
-	".gif",
-	".pdf",
-	".svg",
-	".ico",
-	".woff",
-	".woff2",
-	".eot",
-	".ttf",
-	".otf",
-}
-
 // generateCmd represents the generate command
 var generateCmd = &cobra.Command{
 	Use:   "bundle",
 	Short: "Bundle your project into a single file",
 	Long: `Bundle your project into a single file, starting from the directory you are in.
 By default common configuration and setup files (ex. .vscode, .venv, package.lock) are ignored as well as non-text extensions like .jpeg, .png, .pdf. 
+Detected secrets (private keys, cloud credentials, tokens) are replaced by placeholders like [REDACTED:AWS_ACCESS_KEY].
+With --redact, personal data like emails, phone numbers and IP addresses is replaced by consistent pseudonyms (ex. <EMAIL_1>).
+With --outline, Go, Python, TypeScript and JavaScript files are reduced to their imports, type declarations and function signatures.
 
 For more information see: https://crevcli.com/docs
 
@@ -90,42 +34,39 @@ Example usage:
 crev bundle
 crev bundle --ignore-pre=tests,readme --ignore-ext=.txt 
 crev bundle --ignore-pre=tests,readme --include-ext=.go,.py,.js
+crev bundle --outline
+crev bundle --fail-on-secrets
+crev bundle --redact=email,phone,ipv4
 `,
 	Args: cobra.NoArgs,
 	Run: func(_ *cobra.Command, _ []string) {
 		// start timer
 		start := time.Now()
 
-		// get all file paths from the root directory
-		rootDir := "."
-
-		prefixesToIgnore := viper.GetStringSlice("ignore-pre")
-		prefixesToIgnore = append(prefixesToIgnore, standardPrefixesToIgnore...)
-
-		extensionsToIgnore := viper.GetStringSlice("ignore-ext")
-		extensionsToIgnore = append(extensionsToIgnore, standardExtensionsToIgnore...)
-
-		extensionsToInclude := viper.GetStringSlice("include-ext")
-
-		filePaths, err := files.GetAllFilePaths(rootDir, prefixesToIgnore,
-			extensionsToInclude, extensionsToIgnore)
+		opts, err := bundleOptions()
 		if err != nil {
 			log.Fatal(err)
-			return
 		}
 
-		// generate the project tree
-		projectTree := formatting.GeneratePathTree(filePaths)
-
-		maxConcurrency := 100
-		// get the content of all files
-		fileContentMap, err := files.GetContentMapOfFiles(filePaths, maxConcurrency)
+		// bundle all files starting from the current directory
+		projectBundle, err := bundle.New(opts...).Bundle(context.Background())
+		if projectBundle != nil {
+			for _, finding := range projectBundle.Secrets {
+				log.Printf("Redacted %s in %s:%d", finding.Kind, finding.Path, finding.Line)
+			}
+			for _, rule := range slices.Sorted(maps.Keys(projectBundle.Redactions)) {
+				log.Printf("Redacted %d matches of rule %s", projectBundle.Redactions[rule], rule)
+			}
+		}
+		if errors.Is(err, bundle.ErrSecretsFound) {
+			log.Fatalf("Found %d secrets, no bundle was written because --fail-on-secrets is set", len(projectBundle.Secrets))
+		}
 		if err != nil {
 			log.Fatal(err)
 		}
 
 		// create the project string
-		projectString := formatting.CreateProjectString(projectTree, fileContentMap)
+		projectString := projectBundle.String()
 
 		outputFile := "crev-project.txt"
 		// save the project string to a file
@@ -138,8 +79,8 @@ crev bundle --ignore-pre=tests,readme --include-ext=.go,.py,.js
 		log.Println("Project overview succesfully saved to: " + outputFile)
 
 		// estimate number of tokens
-		log.Printf("Estimated token count: %d - %d tokens",
-			len(projectString)/4, len(projectString)/3)
+		minTokens, maxTokens := tokens.Range(projectString)
+		log.Printf("Estimated token count: %d - %d tokens", minTokens, maxTokens)
 
 		elapsed := time.Since(start)
 		log.Printf("Execution time: %s", elapsed)
@@ -147,11 +88,76 @@ crev bundle --ignore-pre=tests,readme --include-ext=.go,.py,.js
 	},
 }
 
+// redactionRules returns the custom redaction rules configured in the config.
+func redactionRules() ([]bundle.RedactionRule, error) {
+	var rules []bundle.RedactionRule
+	if err := viper.UnmarshalKey("redact-rules", &rules); err != nil {
+		return nil, fmt.Errorf("invalid redact-rules in config: %w", err)
+	}
+	return rules, nil
+}
+
+// redactionOption returns the option of the bundler that redacts the personal data
+// configured by the flags and the config.
+func redactionOption() (bundle.Option, error) {
+	rules, err := redactionRules()
+	if err != nil {
+		return nil, err
+	}
+	return bundle.WithRedaction(viper.GetStringSlice("redact"), rules...), nil
+}
+
+// redactFunc returns a function that redacts the personal data configured by the flags and
+// the config, or nil if no redaction is configured. The same value is always replaced by
+// the same placeholder, also across calls.
+func redactFunc() (func(map[string]string) map[string]string, error) {
+	rules, err := redactionRules()
+	if err != nil {
+		return nil, err
+	}
+	builtins := viper.GetStringSlice("redact")
+	if len(builtins) == 0 && len(rules) == 0 {
+		return nil, nil
+	}
+	redactor, err := redaction.NewRedactor(builtins, rules)
+	if err != nil {
+		return nil, err
+	}
+	return func(fileContentMap map[string]string) map[string]string {
+		redacted, _ := redactor.Redact(fileContentMap)
+		return redacted
+	}, nil
+}
+
+// bundleOptions returns the options of the bundler configured by the flags and the config.
+func bundleOptions() ([]bundle.Option, error) {
+	redaction, err := redactionOption()
+	if err != nil {
+		return nil, err
+	}
+	opts := []bundle.Option{
+		bundle.WithIgnorePrefixes(viper.GetStringSlice("ignore-pre")...),
+		bundle.WithIgnoreExtensions(viper.GetStringSlice("ignore-ext")...),
+		bundle.WithIncludeExtensions(viper.GetStringSlice("include-ext")...),
+		redaction,
+	}
+	if viper.GetBool("outline") {
+		opts = append(opts, bundle.WithOutline())
+	}
+	if viper.GetBool("fail-on-secrets") {
+		opts = append(opts, bundle.WithFailOnSecrets())
+	}
+	return opts, nil
+}
+
 func init() {
 	rootCmd.AddCommand(generateCmd)
 	generateCmd.Flags().StringSlice("ignore-pre", []string{}, "Comma-separated prefixes of file and dir names to ignore. Ex tests,readme")
 	generateCmd.Flags().StringSlice("ignore-ext", []string{}, "Comma-separated file extensions to ignore. Ex .txt,.md")
 	generateCmd.Flags().StringSlice("include-ext", []string{}, "Comma-separated file extensions to include. Ex .go,.py,.js")
+	generateCmd.Flags().Bool("outline", false, "Only include signatures and type declarations of supported files (Go, Python, TypeScript, JavaScript)")
+	generateCmd.Flags().Bool("fail-on-secrets", false, "Exit with an error instead of writing the bundle when secrets are detected")
+	generateCmd.Flags().StringSlice("redact", []string{}, "Comma-separated built-in redaction rules to apply. Ex email,phone,ipv4,ipv6")
 	err := viper.BindPFlag("ignore-pre", generateCmd.Flags().Lookup("ignore-pre"))
 	if err != nil {
 		log.Fatal(err)
@@ -164,4 +170,16 @@ func init() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	err = viper.BindPFlag("outline", generateCmd.Flags().Lookup("outline"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = viper.BindPFlag("fail-on-secrets", generateCmd.Flags().Lookup("fail-on-secrets"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = viper.BindPFlag("redact", generateCmd.Flags().Lookup("redact"))
+	if err != nil {
+		log.Fatal(err)
+	}
 }
@@ -0,0 +1,55 @@
+// Description: This file implements the "cache" command, which manages the reviews cached on disk.
+package cmd
+
+import (
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vossenwout/crev/internal/cache"
+)
+
+// cacheCmd represents the cache command
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the cache of reviews",
+	Long: `Manage the cache of reviews. Reviews are cached in the user cache directory ($XDG_CACHE_HOME/crev/reviews,
+~/.cache/crev/reviews by default on Linux), so reviewing the same code again does not send it once more.
+`,
+}
+
+// cachePruneCmd represents the cache prune command
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove old reviews from the cache",
+	Long: `Remove the reviews that were cached longer ago than --older-than from the cache.
+Use --older-than=0 to empty the cache.
+
+Example usage:
+crev cache prune
+crev cache prune --older-than=168h
+crev cache prune --older-than=0
+`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		olderThan, err := cmd.Flags().GetDuration("older-than")
+		if err != nil {
+			log.Fatal(err)
+		}
+		reviewCache, err := cache.Default()
+		if err != nil {
+			log.Fatal(err)
+		}
+		removed, err := reviewCache.Prune(olderThan)
+		if err != nil {
+			log.Fatalf("Error pruning the cache: %v", err)
+		}
+		log.Printf("Removed %d cached reviews from %s", removed, reviewCache.Dir())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(cachePruneCmd)
+	cachePruneCmd.Flags().Duration("older-than", 30*24*time.Hour, "Remove reviews cached longer ago than this, 0 removes all")
+}
@@ -0,0 +1,133 @@
+// Description: This file implements the "chat" command, which answers follow-up questions about the bundle and its review.
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vossenwout/crev/internal/chat"
+	"github.com/vossenwout/crev/internal/history"
+	"github.com/vossenwout/crev/internal/review"
+	"github.com/vossenwout/crev/pkg/bundle"
+	reviewapi "github.com/vossenwout/crev/pkg/review"
+)
+
+// chatCmd represents the chat command
+var chatCmd = &cobra.Command{
+	Use:   "chat",
+	Short: "Ask follow-up questions about your crev-project.txt and its review",
+	Long: `Start a conversation about the crev-project.txt you generated with the crev bundle command and its latest
+review (crev-review.md, or the latest review in .crev-history), to ask questions like "why is this a race?".
+
+The conversation runs against the provider selected in your .crev-config.yaml or with --provider, which must
+support chat (openai, anthropic or ollama). Every question is sent with the project, the review and the
+conversation so far. Use "/add <path>" to add more files or directories to the conversation, they are filtered
+and redacted like a bundle. The transcript is saved to crev-chat-<time>.md after every answer.
+
+Example usage:
+crev chat
+crev chat --provider=anthropic
+crev chat --provider=ollama --model=llama3.1
+`,
+	Args:   cobra.NoArgs,
+	PreRun: bindProviderFlags,
+	Run: func(cmd *cobra.Command, args []string) {
+		provider, err := newProvider("")
+		if err != nil {
+			log.Fatal(err)
+		}
+		chatProvider, ok := provider.(reviewapi.ChatProvider)
+		if !ok {
+			log.Fatalf("The %s provider does not support chat, select openai, anthropic or ollama with --provider", provider.Name())
+		}
+		dat, err := os.ReadFile("crev-project.txt")
+		if err != nil {
+			log.Fatal("Could not find crev-project.txt. Did you forget to run the \"crev bundle\" command?")
+		}
+		codeToReview := string(dat)
+		latest, err := latestReview()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if latest == "" {
+			log.Println("No review found, the conversation is only about the code.")
+		}
+
+		in := bufio.NewReader(os.Stdin)
+		fmt.Print(review.NewManifest(codeToReview, provider.Destination()))
+		if !review.IsInteractive() {
+			log.Fatal("crev chat needs a terminal to read questions from.")
+		}
+		if !skipConfirmation() && !review.Confirm("Send this code with every question?", in, os.Stdout) {
+			log.Fatal("Chat cancelled, nothing was sent.")
+		}
+
+		transcriptFile := fmt.Sprintf("crev-chat-%s.md", time.Now().Format("20060102-150405"))
+		repl := &chat.REPL{
+			Session:        chat.NewSession(chatProvider, codeToReview, latest),
+			In:             in,
+			Out:            os.Stdout,
+			Load:           loadFiles,
+			TranscriptFile: transcriptFile,
+		}
+		fmt.Print("\n" + chat.Help)
+		if err := repl.Run(); err != nil {
+			log.Fatal(err)
+		}
+		if len(repl.Session.Messages()) > 0 {
+			log.Printf("Saved the transcript to %s", transcriptFile)
+		}
+	},
+}
+
+// latestReview returns the review in crev-review.md or, if there is none, the latest
+// review in the history. It returns an empty string if there is no review.
+func latestReview() (string, error) {
+	dat, err := os.ReadFile("crev-review.md")
+	if err == nil {
+		return string(dat), nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+	runs, err := history.New(history.DefaultDir).List()
+	if err != nil || len(runs) == 0 {
+		return "", err
+	}
+	return runs[len(runs)-1].Review, nil
+}
+
+// loadFiles bundles the file or the files in the directory at the path, filtered and
+// redacted like a bundle.
+func loadFiles(path string) (string, string, error) {
+	opts, err := bundleOptions()
+	if err != nil {
+		return "", "", err
+	}
+	loaded, err := bundle.New(append(opts, bundle.WithPaths(path))...).Bundle(context.Background())
+	if errors.Is(err, bundle.ErrSecretsFound) {
+		return "", "", fmt.Errorf("found %d secrets, nothing was added because fail-on-secrets is set", len(loaded.Secrets))
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if len(loaded.Files) == 0 {
+		return "", "", errors.New("no files to add, they may all be ignored")
+	}
+	for _, finding := range loaded.Secrets {
+		log.Printf("Redacted %s in %s:%d", finding.Kind, finding.Path, finding.Line)
+	}
+	return loaded.String(), fmt.Sprintf("%s (%d files)", path, len(loaded.Files)), nil
+}
+
+func init() {
+	rootCmd.AddCommand(chatCmd)
+	addProviderFlags(chatCmd)
+}
@@ -0,0 +1,224 @@
+// Description: This file implements the "fix" command, which applies patches for the findings of a review.
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/vossenwout/crev/internal/fix"
+	"github.com/vossenwout/crev/internal/formatting"
+	"github.com/vossenwout/crev/internal/review"
+	"github.com/vossenwout/crev/pkg/bundle"
+	reviewapi "github.com/vossenwout/crev/pkg/review"
+)
+
+// fixCmd represents the fix command
+var fixCmd = &cobra.Command{
+	Use:   "fix [finding...]",
+	Short: "Apply patches that fix the findings of your latest review",
+	Long: `Ask the provider for patches that fix findings of your latest review, saved in crev-findings.json by the
+crev review command. Select findings by their number (ex. 1 3 or 2-4) or fix all of them with --all. Without a
+selection the findings are listed and you are asked which to fix.
+
+The current content of the files with the selected findings is redacted like a bundle and sent to the provider
+selected in your .crev-config.yaml or with --provider, which must support chat (openai, anthropic or ollama).
+Only files of crev-project.txt can be fixed, patches that change other files or create, delete or rename files
+are rejected. Every patch is checked with git apply, shown in a colored preview and applied only after you
+confirm. Use --yes to skip the confirmations, unless require-confirmation is set in your .crev-config.yaml.
+
+Example usage:
+crev fix
+crev fix 1 3
+crev fix 2-4 --provider=anthropic
+crev fix --all
+`,
+	PreRun: bindProviderFlags,
+	Run: func(cmd *cobra.Command, args []string) {
+		provider, err := newProvider("")
+		if err != nil {
+			log.Fatal(err)
+		}
+		chatProvider, ok := provider.(reviewapi.ChatProvider)
+		if !ok {
+			log.Fatalf("The %s provider cannot write patches, select openai, anthropic or ollama with --provider", provider.Name())
+		}
+		findings, err := fixableFindings()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(findings) == 0 {
+			log.Println("There are no findings to fix.")
+			return
+		}
+
+		in := bufio.NewReader(os.Stdin)
+		selection := strings.Join(args, " ")
+		if all, _ := cmd.Flags().GetBool("all"); all {
+			selection = "all"
+		}
+		if selection == "" {
+			fmt.Print("Findings:\n" + numberedFindings(findings))
+			if !review.IsInteractive() {
+				log.Fatal("Select the findings to fix by their number or use --all.")
+			}
+			fmt.Print("Findings to fix (ex. 1,3 or all): ")
+			selection, _ = in.ReadString('\n')
+		}
+		indexes, err := fix.ParseSelection(strings.TrimSpace(selection), len(findings))
+		if err != nil {
+			log.Fatal(err)
+		}
+		var selected []reviewapi.Finding
+		var paths []string
+		for _, i := range indexes {
+			selected = append(selected, findings[i])
+			if !slices.Contains(paths, findings[i].Path) {
+				paths = append(paths, findings[i].Path)
+			}
+		}
+
+		// Send the current content of the files, which may have changed since the review.
+		redaction, err := redactionOption()
+		if err != nil {
+			log.Fatal(err)
+		}
+		current, err := bundle.New(bundle.WithPaths(paths...), bundle.WithoutDefaultIgnores(), redaction).Bundle(context.Background())
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, finding := range current.Secrets {
+			log.Printf("Redacted %s in %s:%d, patches cannot change the redacted lines", finding.Kind, finding.Path, finding.Line)
+		}
+		system, message := fix.Request(selected, current.Files)
+
+		fmt.Print(review.NewManifest(current.String(), provider.Destination()))
+		if !confirm(in, "Send these files to fix the selected findings?") {
+			log.Fatal("Fix cancelled, nothing was sent.")
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		log.Printf("Asking for patches for %d findings please wait...", len(selected))
+		answer, err := chatProvider.Chat(ctx, system, []reviewapi.Message{{Role: "user", Content: message}}, nil)
+		if err != nil {
+			log.Printf("Failed to get patches: %v", err)
+			os.Exit(review.ExitCode(err))
+		}
+
+		allowed := make(map[string]bool, len(current.Files))
+		for path := range current.Files {
+			allowed[path] = true
+		}
+		patches, errs := fix.ExtractPatches(answer, allowed)
+		for _, err := range errs {
+			log.Printf("Rejected %v", err)
+		}
+		opts := fix.Options{Dir: "."}
+		var valid []fix.Patch
+		for i, patch := range patches {
+			if err := fix.Check(ctx, patch, opts); err != nil {
+				log.Printf("Patch %d for %s does not apply cleanly: %v", i+1, strings.Join(patch.Paths, ", "), err)
+				continue
+			}
+			valid = append(valid, patch)
+		}
+		if len(valid) == 0 {
+			log.Fatal("No patches to apply.")
+		}
+
+		for _, patch := range valid {
+			if colorOutput() {
+				fmt.Print("\n" + fix.Colorize(patch.Diff))
+			} else {
+				fmt.Print("\n" + patch.Diff)
+			}
+		}
+		fmt.Println()
+		if !confirm(in, fmt.Sprintf("Apply these %d patches?", len(valid))) {
+			log.Fatal("Fix cancelled, no files were changed.")
+		}
+		failed := 0
+		for _, patch := range valid {
+			// Check again, an earlier patch may have changed the same file.
+			err := fix.Check(ctx, patch, opts)
+			if err == nil {
+				err = fix.Apply(ctx, patch, opts)
+			}
+			if err != nil {
+				log.Printf("Could not apply the patch for %s: %v", strings.Join(patch.Paths, ", "), err)
+				failed++
+				continue
+			}
+			log.Printf("Applied the patch for %s", strings.Join(patch.Paths, ", "))
+		}
+		if failed > 0 {
+			os.Exit(review.ExitFailure)
+		}
+	},
+}
+
+// fixableFindings returns the findings of the latest review in files of the bundle.
+func fixableFindings() ([]reviewapi.Finding, error) {
+	dat, err := os.ReadFile(review.FindingsFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not find %s. Did you forget to run the \"crev review\" command?", review.FindingsFile)
+	}
+	var report review.FindingsReport
+	if err := json.Unmarshal(dat, &report); err != nil {
+		return nil, fmt.Errorf("error decoding %s: %w", review.FindingsFile, err)
+	}
+	dat, err = os.ReadFile("crev-project.txt")
+	if err != nil {
+		return nil, errors.New("could not find crev-project.txt. Did you forget to run the \"crev bundle\" command?")
+	}
+	bundled := formatting.ParseProjectString(string(dat))
+	var findings []reviewapi.Finding
+	for _, finding := range report.Findings {
+		if _, ok := bundled[finding.Path]; !ok {
+			log.Printf("Skipping the finding in %s, which is not a file of crev-project.txt", finding.Path)
+			continue
+		}
+		findings = append(findings, finding)
+	}
+	return findings, nil
+}
+
+// numberedFindings returns one line per finding, preceded by its number.
+func numberedFindings(findings []reviewapi.Finding) string {
+	var sb strings.Builder
+	for i, finding := range findings {
+		fmt.Fprintf(&sb, "%3d. %s\n", i+1, finding)
+	}
+	return sb.String()
+}
+
+// confirm asks the question unless confirmation is skipped, and exits if it cannot be asked.
+func confirm(in *bufio.Reader, question string) bool {
+	if skipConfirmation() {
+		return true
+	}
+	if !review.IsInteractive() {
+		log.Fatal("Refusing to continue without confirmation. Run with --yes to confirm non-interactively.")
+	}
+	return review.Confirm(question, in, os.Stdout)
+}
+
+// colorOutput returns true if stdout is a terminal and colors are not disabled with NO_COLOR.
+func colorOutput() bool {
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0 && os.Getenv("NO_COLOR") == ""
+}
+
+func init() {
+	rootCmd.AddCommand(fixCmd)
+	addProviderFlags(fixCmd)
+	fixCmd.Flags().Bool("all", false, "Fix all findings of the latest review")
+}
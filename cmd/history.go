@@ -0,0 +1,68 @@
+// Description: This file implements the "review history" and "review compare" commands, which show the reviews kept in the history.
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/vossenwout/crev/internal/history"
+	"github.com/vossenwout/crev/internal/review"
+)
+
+// historyCmd represents the review history command
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List the reviews kept in the history",
+	Long: `List the reviews kept in .crev-history with the time of the run, the git commit that was checked out,
+the provider and model, a hash of the reviewed code and the number of findings.
+
+Example usage:
+crev review history
+`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		h := history.New(history.DefaultDir)
+		runs, err := h.List()
+		if err != nil {
+			log.Fatalf("Error reading the history: %v", err)
+		}
+		if len(runs) == 0 {
+			log.Printf("There are no reviews in %s yet", h.Dir())
+			return
+		}
+		fmt.Print(review.FormatHistory(runs))
+	},
+}
+
+// compareCmd represents the review compare command
+var compareCmd = &cobra.Command{
+	Use:   "compare <a> <b>",
+	Short: "Show which findings were fixed, persisted or introduced between two reviews",
+	Long: `Compare the findings of two reviews in the history. Reviews are referred to by their ID as listed by
+"crev review history", a prefix of the ID that matches a single review, or "latest".
+Findings are matched by the code around them, so they are recognized when other code moves.
+
+Example usage:
+crev review compare 20240601-101500 latest
+crev review compare 20240601 20240615
+`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		h := history.New(history.DefaultDir)
+		a, err := h.Get(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		b, err := h.Get(args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(review.FormatComparison(a, b, history.Compare(a, b)))
+	},
+}
+
+func init() {
+	reviewCmd.AddCommand(historyCmd)
+	reviewCmd.AddCommand(compareCmd)
+}
@@ -16,12 +16,67 @@ var defaultConfig = []byte(`# Configuration for the crev tool
 # specify your CREV API key (necessary for review command) ! this overwrites the value you specify in the environment variable
 # you can get one on: https://crevcli.com/api-key
 crev_api_key: # ex. csk_8e796a8f6fdb15f0902eee0d4138b9d5975e244e6cc61ef502feaf37af24c7cb
+# specify the review provider: crev (default), openai, anthropic or ollama
+provider: # ex. anthropic
+# specify the model used by the provider (openai, anthropic and ollama have a default)
+model: # ex. gpt-4o
+# specify a different endpoint for the provider, ex. an OpenAI compatible llama.cpp server
+base_url: # ex. http://localhost:8080/v1
+# specify the environment variable that holds the API key of the provider (defaults to OPENAI_API_KEY or ANTHROPIC_API_KEY)
+api_key_env: # ex. COMPANY_LLM_API_KEY
+# specify how review requests are sent (timeout defaults to 10m)
+timeout: # ex. 5m
+proxy: # ex. http://proxy.internal:3128
+ca_cert: # ex. certs/company-ca.pem
+client_cert: # ex. certs/client.pem
+client_key: # ex. certs/client-key.pem
+headers: # ex. {X-Team: platform}
+# number of times a review request is sent before a transient failure is reported (defaults to 4)
+max_attempts: # ex. 6
+# number of unchanged lines around every hunk and the maximum number of lines of touched files sent in full with review --diff
+context_lines: # ex. 20
+full_file_lines: # ex. 500
+# format of the saved review: markdown (crev-review.md, default) or sarif (crev-review.sarif)
+format: # ex. sarif
+# fail the review command with exit code 2 when a finding has at least this severity: critical, high, medium, low or info
+fail-on: # ex. high
+# keep only this many of the most serious findings
+max-findings: # ex. 20
+# baseline file with accepted findings that are not reported, written with review --write-baseline
+baseline: # ex. .crev-baseline.json
+# review projects of more estimated tokens in parts of at most this size (defaults to 100000, 0 disables it)
+chunk_tokens: # ex. 50000
+# number of parts of a large project that are reviewed at the same time (defaults to 4)
+concurrency: # ex. 8
+# instructions sent with every review request, in addition to the rules in .crev-rules.md
+review:
+  instructions: # ex. All HTTP handlers must take a context.Context as their first argument.
+# concentrate reviews on security, performance, tests or readability
+focus: # ex. [security]
+# always request a new review instead of using the review cached for the same code
+no-cache: # ex. true
+# do not send projects of more estimated tokens with crev ask
+max_tokens: # ex. 50000
+# do not send reviews that are estimated to cost more than this many US dollars (needs a known price, see prices)
+max_cost: # ex. 1.50
+# prices in US dollars per million tokens, overriding the built-in prices (the model can be a pattern)
+prices: # ex. [{provider: openai, model: gpt-4o*, input: 2.5, output: 10}]
+# always ask for confirmation before code is sent for review, even when --yes is passed
+require-confirmation: # ex. true
 # specify the prefixes of files and directories to ignore (by default common configuration files are ignored)
 ignore-pre: # ex. [tests, readme.md, scripts]
 # specify the extensions of files to ignore 
 ignore-ext: # ex. [.go, .py, .js]
 # specify the extensions of files to include 
 include-ext: # ex. [.go, .py, .js]
+# only include signatures and type declarations of supported files instead of their full content
+outline: # ex. true
+# fail instead of writing the bundle when secrets are detected (secrets are always redacted)
+fail-on-secrets: # ex. true
+# replace personal data by pseudonyms, the same value always gets the same placeholder (ex. <EMAIL_1>)
+redact: # ex. [email, phone, ipv4, ipv6]
+# custom redaction rules, matches are replaced by placeholders named after the rule (ex. <CUSTOMER_ID_1>)
+redact-rules: # ex. [{name: customer_id, pattern: 'CUST-[0-9]{6}'}]
 `)
 
 var initCmd = &cobra.Command{
@@ -0,0 +1,104 @@
+// Description: This file contains the flags and config shared by the commands that send code to a provider.
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	reviewapi "github.com/vossenwout/crev/pkg/review"
+)
+
+// Flags that select and configure the provider, shared by the commands that send code to it.
+var providerFlags = []string{"crev_api_key", "provider", "model", "base_url", "timeout", "proxy", "ca_cert",
+	"client_cert", "client_key", "header", "max_attempts", "yes"}
+
+// addProviderFlags adds the flags that select and configure the provider to the command.
+func addProviderFlags(cmd *cobra.Command) {
+	cmd.Flags().String("crev_api_key", "", "Your Code AI Review API key ")
+	cmd.Flags().String("provider", "", "Review provider: crev (default), openai, anthropic or ollama")
+	cmd.Flags().String("model", "", "Model used by the review provider")
+	cmd.Flags().String("base_url", "", "Endpoint of the review provider, ex. a proxy or local mock")
+	cmd.Flags().Duration("timeout", 10*time.Minute, "Timeout of review requests, 0 disables it")
+	cmd.Flags().String("proxy", "", "HTTP(S) proxy URL, defaults to the HTTPS_PROXY env var")
+	cmd.Flags().String("ca_cert", "", "Path to a PEM bundle of additional certificate authorities to trust")
+	cmd.Flags().String("client_cert", "", "Path to a PEM TLS client certificate")
+	cmd.Flags().String("client_key", "", "Path to the PEM key of the TLS client certificate")
+	cmd.Flags().StringArray("header", []string{}, "Extra request header as \"Name: value\", can be repeated")
+	cmd.Flags().Int("max_attempts", 4, "Number of times a request is sent before a transient failure is reported")
+	cmd.Flags().BoolP("yes", "y", false, "Send the code without asking for confirmation")
+}
+
+// bindProviderFlags binds the provider flags of the command that runs to the config. This
+// happens when the command runs, as several commands have these flags.
+func bindProviderFlags(cmd *cobra.Command, args []string) {
+	for _, name := range providerFlags {
+		err := viper.BindPFlag(name, cmd.Flags().Lookup(name))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// skipConfirmation returns true if code can be sent without asking the user first.
+func skipConfirmation() bool {
+	if viper.GetBool("yes") && viper.GetBool("require-confirmation") {
+		log.Println("Ignoring --yes because require-confirmation is set in the config.")
+		return false
+	}
+	return viper.GetBool("yes")
+}
+
+// newProvider creates the review provider selected in the config, together with its API key.
+func newProvider(instructions string) (reviewapi.Provider, error) {
+	providerName := viper.GetString("provider")
+	apiKey := ""
+	if env := viper.GetString("api_key_env"); env != "" {
+		apiKey = os.Getenv(env)
+		if apiKey == "" {
+			return nil, fmt.Errorf("the environment variable %s configured as api_key_env is not set", env)
+		}
+	} else if providerName == "" || providerName == "crev" {
+		apiKey = viper.GetString("crev_api_key")
+		if apiKey == "" {
+			return nil, errors.New(`Api key is required for review. Get yours on: https://crevcli.com/api-key and set it as CREV_API_KEY env var or specify it under 'crev_api_key' key in your .crev-config.yaml. For more information see: https://crevcli.com/docs`)
+		}
+	} else if env := reviewapi.DefaultAPIKeyEnv(providerName); env != "" {
+		apiKey = os.Getenv(env)
+		if apiKey == "" {
+			return nil, fmt.Errorf("api key is required for provider %s, set it as %s env var or configure api_key_env in your .crev-config.yaml", providerName, env)
+		}
+	}
+	headers := viper.GetStringMapString("headers")
+	for _, header := range viper.GetStringSlice("header") {
+		key, value, found := strings.Cut(header, ":")
+		if !found {
+			return nil, fmt.Errorf("invalid header %q, expected format is \"Name: value\"", header)
+		}
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return reviewapi.NewProvider(reviewapi.Options{
+		Provider:     providerName,
+		Model:        viper.GetString("model"),
+		BaseURL:      viper.GetString("base_url"),
+		APIKey:       apiKey,
+		Instructions: instructions,
+		HTTP: reviewapi.HTTPOptions{
+			Timeout:     viper.GetDuration("timeout"),
+			Proxy:       viper.GetString("proxy"),
+			CACert:      viper.GetString("ca_cert"),
+			ClientCert:  viper.GetString("client_cert"),
+			ClientKey:   viper.GetString("client_key"),
+			Headers:     headers,
+			MaxAttempts: viper.GetInt("max_attempts"),
+			OnRetry: func(err error, delay time.Duration, attempt int) {
+				log.Printf("%v, retrying in %s (attempt %d of %d)", err, delay.Round(time.Millisecond), attempt, viper.GetInt("max_attempts"))
+			},
+		},
+	})
+}
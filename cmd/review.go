@@ -1,40 +1,322 @@
 package cmd
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"slices"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/vossenwout/crev/internal/baseline"
+	"github.com/vossenwout/crev/internal/cache"
+	"github.com/vossenwout/crev/internal/cost"
+	"github.com/vossenwout/crev/internal/gitdiff"
+	"github.com/vossenwout/crev/internal/history"
 	"github.com/vossenwout/crev/internal/review"
+	"github.com/vossenwout/crev/internal/sarif"
+	"github.com/vossenwout/crev/pkg/bundle"
+	reviewapi "github.com/vossenwout/crev/pkg/review"
 )
 
 // reviewCmd represents the review command
 var reviewCmd = &cobra.Command{
-	Use:   "review",
+	Use:   "review [base]",
 	Short: "Let an AI review your crev-project.txt",
 	Long: `Let an AI review the crev-project.txt you generated with the crev bundle command. 
 
-This command requires a CREV_API_KEY to be set as an environment variable or in your .crev-config.yaml.
-You can generate a CREV_API_KEY on the crev website. For more information see: https://crevcli.com/docs`,
+By default the code is reviewed by the crev service, which requires a CREV_API_KEY to be set as an environment variable or in your .crev-config.yaml.
+You can generate a CREV_API_KEY on the crev website. For more information see: https://crevcli.com/docs
+
+To use a model approved by your company instead, select a provider (openai, anthropic or ollama) with
+the provider, model, base_url and api_key_env keys in your .crev-config.yaml or the --provider and --model flags.
+
+Requests can be routed through a proxy or an internal gateway with --base_url, --proxy, --ca_cert,
+--client_cert, --client_key and --header, or the matching keys in your .crev-config.yaml.
+
+Before anything is uploaded a summary of the bundle is shown and you are asked for confirmation.
+Use --yes to skip the confirmation in CI, unless require-confirmation is set in your .crev-config.yaml.
+
+To review only your local changes instead of the whole project, use --diff. The changes are compared to the
+commit where your branch forked from the given base (HEAD by default), including uncommitted and untracked files.
+Every hunk is sent with --context_lines unchanged lines around it, and touched files with at most --full_file_lines
+lines are sent in full. The diff is filtered and redacted like a bundle, no crev-project.txt is needed.
+
+The review is shown in the terminal while it is written and saved to crev-review.md once it is complete.
+The issues it raises are saved as structured findings (file, lines, severity, category, message and suggestion)
+to crev-findings.json, after checking that they refer to reviewed files and lines. With --format=sarif the findings
+are saved as a SARIF 2.1.0 log to crev-review.sarif instead of crev-review.md, for code scanning dashboards.
+If the stream is interrupted, the part received so far is saved. Use --stream=false to wait for the complete review.
+
+In CI, use --fail-on to fail the build when a finding is at least as serious as the given severity (critical, high,
+medium, low or info). A one line summary of every finding is printed after the review, and --max-findings keeps only
+the most serious findings when there are more.
+
+Conventions of your team that the review must check, like "all HTTP handlers must take a context", can be written
+in a .crev-rules.md file in the current directory or under review.instructions in your .crev-config.yaml. They are
+sent with every review request. Use --focus to concentrate the review on security, performance, tests or readability.
+
+Projects that are too large for a single request are split into parts of at most --chunk_tokens estimated tokens,
+keeping directories together. The parts are reviewed concurrently (--concurrency at a time) and their reviews are
+combined into a single review without duplicate findings.
+
+Findings that are intentional can be suppressed in the code with a "crev:ignore <category> <reason>" comment. It
+applies to the next line, or the whole block if that line opens one, and to the enclosing block when nothing follows
+it in that block. Suppressed findings are not reported, but are listed with their reasons in the review.
+
+To adopt --fail-on in a project with existing findings, accept them with --write-baseline, which records them in
+.crev-baseline.json (or the file given with --baseline). Reviews with --baseline only report findings that are not
+in the baseline. Findings are recognized by the code around them, so they stay accepted when other code moves.
+
+Every review is also kept in .crev-history with the time, git commit, provider, model and a hash of the reviewed
+code. Use "crev review history" to list the reviews and "crev review compare <a> <b>" to see which findings were
+fixed, persisted or introduced between two of them. The history keeps all findings, also those that are suppressed
+or accepted in the baseline.
+
+Reviews are cached in the user cache directory ($XDG_CACHE_HOME/crev/reviews, ~/.cache/crev/reviews by default on
+Linux), keyed by the reviewed code, the provider, the model and the instructions. Reviewing the same code again
+returns the cached review at once without sending anything. Use --no-cache to request a new review and
+"crev cache prune" to remove old reviews from the cache.
+
+Use --estimate to print the expected input and output tokens and cost of a review without sending it. Costs are
+based on built-in list prices per provider and model, which can be overridden under prices in your .crev-config.yaml.
+Set max_cost to abort reviews that are estimated to cost more than that many US dollars. It needs a provider and
+model with a known price, so reviews by the crev provider are aborted unless its price is set under prices. Cached
+reviews are never aborted.
+
+Failed requests caused by rate limits, overloaded servers or network errors are retried with exponential backoff.
+The command exits with code 2 when findings meet the --fail-on threshold, 3 when the API key is rejected,
+4 when the quota is exhausted and 5 when a transient error persists after all attempts. With --fail-on, a review
+whose findings cannot be read, like one that does not list them, exits with code 1 rather than passing.
+
+Example usage:
+crev review
+crev review --provider=openai --model=gpt-4o
+crev review --base_url=https://llm-gateway.internal/v1 --ca_cert=company-ca.pem --header="X-Team: platform"
+crev review --proxy=http://proxy.internal:3128 --timeout=5m
+crev review --yes --max_attempts=6
+crev review --diff
+crev review --diff main --context_lines=20
+crev review --format=sarif
+crev review --yes --stream=false --fail-on=high --max-findings=20
+crev review --write-baseline
+crev review --chunk_tokens=50000 --concurrency=8
+crev review --focus=security --focus=tests
+crev review --no-cache
+crev review --estimate --provider=anthropic
+crev review --max_cost=0.50
+crev review --baseline=.crev-baseline.json --fail-on=medium
+`,
+	Args:   cobra.MaximumNArgs(1),
+	PreRun: bindProviderFlags,
 	Run: func(cmd *cobra.Command, args []string) {
-		apiKey := viper.GetString("crev_api_key")
-		if apiKey == "" {
-			log.Fatal(`Api key is required for review. Get yours on: https://crevcli.com/api-key and set it as CREV_API_KEY env var or specify it under 'crev_api_key' key in your .crev-config.yaml. For more information see: https://crevcli.com/docs`)
+		instructions, err := reviewInstructions()
+		if err != nil {
+			log.Fatal(err)
+		}
+		var reviewCache *cache.Cache
+		if !viper.GetBool("no-cache") {
+			reviewCache, err = cache.Default()
+			if err != nil {
+				log.Printf("Reviewing without cache: %v", err)
+			}
+		}
+		if len(args) > 0 && !viper.GetBool("diff") {
+			log.Fatalf("A base ref (%s) can only be given together with --diff", args[0])
+		}
+		format := strings.ToLower(viper.GetString("format"))
+		if !slices.Contains(review.Formats, format) {
+			log.Fatalf("Unknown format %q, available formats: %s", format, strings.Join(review.Formats, ", "))
+		}
+		var failOn reviewapi.Severity
+		if threshold := viper.GetString("fail-on"); threshold != "" {
+			failOn, err = reviewapi.ParseSeverity(threshold)
+			if err != nil {
+				log.Fatal(err)
+			}
 		}
-		dat, err := os.ReadFile("crev-project.txt")
+		baselineFile := viper.GetString("baseline")
+		if baselineFile == "" && viper.GetBool("write-baseline") {
+			baselineFile = baseline.DefaultFile
+		}
+		var codeToReview string
+		var lineCounts map[string]int
+		var contents map[string]string
+		// Paths of a diff are relative to the root of the repository, those of a bundle
+		// to the current directory.
+		pathPrefix := ""
+		if viper.GetBool("diff") {
+			base := "HEAD"
+			if len(args) > 0 {
+				base = args[0]
+			}
+			payload, err := diffPayload(base)
+			if err != nil {
+				log.Fatal(err)
+			}
+			codeToReview, lineCounts, contents = payload.Text, payload.LineCounts, payload.Files
+		} else {
+			dat, err := os.ReadFile("crev-project.txt")
+			if err != nil {
+				log.Fatal("Could not find crev-project.txt. Did you forget to run the \"crev bundle\" command?")
+			}
+			codeToReview = string(dat)
+			pathPrefix = gitdiff.Prefix(context.Background(), ".")
+		}
+		if viper.GetBool("estimate") {
+			estimate, err := estimateCost(codeToReview, instructions)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Print(estimate)
+			return
+		}
+		provider, err := newProvider(instructions)
 		if err != nil {
-			log.Fatal("Could not find crev-project.txt. Did you forget to run the \"crev bundle\" command?")
+			log.Fatal(err)
 		}
-		review.Review(string(dat), apiKey)
+		// A cached review costs nothing, so it is never aborted.
+		if maxCost := viper.GetFloat64("max_cost"); maxCost > 0 && !review.Cached(reviewCache, codeToReview, provider, instructions) {
+			estimate, err := estimateCost(codeToReview, instructions)
+			if err != nil {
+				log.Fatal(err)
+			}
+			checkMaxCost(estimate, maxCost)
+		}
+		review.Review(codeToReview, provider, review.Options{
+			SkipConfirmation: skipConfirmation(),
+			Stream:           viper.GetBool("stream"),
+			LineCounts:       lineCounts,
+			Format:           format,
+			SARIF:            sarif.Options{ToolVersion: Version, PathPrefix: pathPrefix},
+			FailOn:           failOn,
+			MaxFindings:      viper.GetInt("max-findings"),
+			Files:            contents,
+			Baseline:         baselineFile,
+			WriteBaseline:    viper.GetBool("write-baseline"),
+			ChunkTokens:      viper.GetInt("chunk_tokens"),
+			Concurrency:      viper.GetInt("concurrency"),
+			Cache:            reviewCache,
+			Instructions:     instructions,
+			History:          history.New(history.DefaultDir),
+			Model:            viper.GetString("model"),
+		})
 	},
 }
 
+// diffPayload builds the code to review from the local changes compared to the base ref,
+// filtered and redacted like a bundle.
+func diffPayload(base string) (*gitdiff.Payload, error) {
+	redact, err := redactFunc()
+	if err != nil {
+		return nil, err
+	}
+	payload, err := gitdiff.Build(context.Background(), gitdiff.Options{
+		Base:              base,
+		ContextLines:      viper.GetInt("context_lines"),
+		MaxFullFileLines:  viper.GetInt("full_file_lines"),
+		IgnorePrefixes:    slices.Concat(viper.GetStringSlice("ignore-pre"), bundle.DefaultIgnorePrefixes),
+		IgnoreExtensions:  slices.Concat(viper.GetStringSlice("ignore-ext"), bundle.DefaultIgnoreExtensions),
+		IncludeExtensions: viper.GetStringSlice("include-ext"),
+		Redact:            redact,
+	})
+	if errors.Is(err, gitdiff.ErrNoChanges) {
+		return nil, fmt.Errorf("there are no changes compared to %s to review", base)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Line numbers of secrets point into the diff, so only the file is reported.
+	for _, finding := range payload.Secrets {
+		log.Printf("Redacted %s in the changes of %s", finding.Kind, finding.Path)
+	}
+	if len(payload.Secrets) > 0 && viper.GetBool("fail-on-secrets") {
+		return nil, fmt.Errorf("found %d secrets in the changes, nothing was sent because fail-on-secrets is set", len(payload.Secrets))
+	}
+	return payload, nil
+}
+
+// estimateCost estimates the tokens and price of a review of the code by the selected provider.
+func estimateCost(codeToReview string, instructions string) (cost.Estimate, error) {
+	var prices []cost.PriceRule
+	if err := viper.UnmarshalKey("prices", &prices); err != nil {
+		return cost.Estimate{}, fmt.Errorf("invalid prices in config: %w", err)
+	}
+	providerName := strings.ToLower(viper.GetString("provider"))
+	if providerName == "" {
+		providerName = "crev"
+	}
+	model := viper.GetString("model")
+	if model == "" {
+		model = reviewapi.DefaultModel(providerName)
+	}
+	// Chat models combine the reviews of chunks in a request of their own. The provider is
+	// only created to find out, nothing is sent.
+	provider, err := reviewapi.NewProvider(reviewapi.Options{Provider: providerName})
+	if err != nil {
+		return cost.Estimate{}, err
+	}
+	_, combine := provider.(reviewapi.ChatProvider)
+	return cost.New(codeToReview, cost.Options{
+		Provider:     providerName,
+		Model:        model,
+		Instructions: instructions,
+		ChunkTokens:  viper.GetInt("chunk_tokens"),
+		Combine:      combine,
+		Prices:       prices,
+	}), nil
+}
+
+// checkMaxCost exits if the review is estimated to cost more than the maximum, or if its
+// price is unknown so the maximum cannot be checked.
+func checkMaxCost(estimate cost.Estimate, maxCost float64) {
+	if !estimate.KnownPrice {
+		log.Fatalf("Nothing was sent, max_cost is set but the price of %s %s is unknown. Set it under prices in your .crev-config.yaml.", estimate.Provider, estimate.Model)
+	}
+	if estimate.MaxCost() > maxCost {
+		log.Fatalf("Nothing was sent, the review is estimated to cost up to $%.2f which is more than max_cost ($%.2f). Use --estimate for details.", estimate.MaxCost(), maxCost)
+	}
+	log.Printf("The review is estimated to cost up to $%.2f", estimate.MaxCost())
+}
+
+// reviewInstructions returns the instructions of the team and the focus presets that are
+// sent with review requests.
+func reviewInstructions() (string, error) {
+	instructions, sources, err := review.Instructions(review.RulesFile, viper.GetString("review.instructions"), viper.GetStringSlice("focus"))
+	if err != nil {
+		return "", err
+	}
+	if len(sources) > 0 {
+		log.Printf("Sending review instructions from %s", strings.Join(sources, ", "))
+	}
+	return instructions, nil
+}
+
 func init() {
 	rootCmd.AddCommand(reviewCmd)
-	reviewCmd.Flags().String("crev_api_key", "", "Your Code AI Review API key ")
-	err := viper.BindPFlag("crev_api_key", reviewCmd.Flags().Lookup("crev_api_key"))
-	if err != nil {
-		log.Fatal(err)
+	addProviderFlags(reviewCmd)
+	reviewCmd.Flags().Bool("stream", true, "Show the review in the terminal while it is written")
+	reviewCmd.Flags().Bool("diff", false, "Review only the changes compared to the base ref (HEAD by default)")
+	reviewCmd.Flags().Int("context_lines", 10, "Number of unchanged lines shown around every hunk with --diff")
+	reviewCmd.Flags().Int("full_file_lines", 300, "Touched files with at most this many lines are sent in full with --diff")
+	reviewCmd.Flags().String("format", "markdown", "Format of the saved review: markdown or sarif")
+	reviewCmd.Flags().String("fail-on", "", "Exit with code 2 when a finding has at least this severity: critical, high, medium, low or info")
+	reviewCmd.Flags().Int("max-findings", 0, "Keep only this many of the most serious findings, 0 keeps all")
+	reviewCmd.Flags().String("baseline", "", "Do not report the findings accepted in this baseline file")
+	reviewCmd.Flags().Bool("write-baseline", false, "Accept all findings of this review by writing them to the baseline file")
+	reviewCmd.Flags().Int("chunk_tokens", 100000, "Review projects of more estimated tokens in parts of at most this size, 0 disables it")
+	reviewCmd.Flags().Int("concurrency", reviewapi.DefaultConcurrency, "Number of parts of a large project that are reviewed at the same time")
+	reviewCmd.Flags().StringSlice("focus", []string{}, "Concentrate the review on security, performance, tests or readability, can be repeated")
+	reviewCmd.Flags().Bool("estimate", false, "Print the expected tokens and cost of the review without sending it")
+	reviewCmd.Flags().Float64("max_cost", 0, "Do not send reviews estimated to cost more than this many US dollars, 0 disables it")
+	reviewCmd.Flags().Bool("no-cache", false, "Request a new review even if this code has been reviewed before")
+	for _, name := range []string{"stream", "diff", "context_lines", "full_file_lines", "format", "fail-on", "max-findings", "baseline", "write-baseline", "chunk_tokens", "concurrency", "focus", "no-cache", "estimate", "max_cost"} {
+		err := viper.BindPFlag(name, reviewCmd.Flags().Lookup(name))
+		if err != nil {
+			log.Fatal(err)
+		}
 	}
 }
@@ -0,0 +1,86 @@
+// Package ask answers one-off questions about a project, with citations of the files and
+// lines the answer is based on.
+package ask
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+)
+
+// The instructions of the model, followed by the project.
+const prompt = `You are an experienced software engineer who knows the project below inside out. Answer the
+question of the user about it concisely, in markdown. Every line of the files is prefixed with its number.
+
+Base your answer on the code. Cite the code that supports every statement as path:start-end, for example
+internal/server/auth.go:12-30, using the paths as they appear in the project and the line numbers of the
+files. If the answer is not in the code, say so instead of guessing.
+
+# Project
+
+`
+
+// Prompt returns the system prompt with the bundle of the project, whose lines must be numbered.
+func Prompt(bundle string) string {
+	return prompt + bundle
+}
+
+// Matches a citation: a path followed by a line or a range of lines.
+var citationPattern = regexp.MustCompile(`([\w./\\-]+):(\d+)(?:-(\d+))?`)
+
+// Citation refers to lines of a file that an answer is based on.
+type Citation struct {
+	Path      string
+	StartLine int
+	EndLine   int
+}
+
+// String returns the citation as "path:start-end", or "path:line" for a single line.
+func (c Citation) String() string {
+	if c.StartLine == c.EndLine {
+		return fmt.Sprintf("%s:%d", c.Path, c.StartLine)
+	}
+	return fmt.Sprintf("%s:%d-%d", c.Path, c.StartLine, c.EndLine)
+}
+
+// Citations returns the citations in the answer that refer to lines of bundled files,
+// sorted and without duplicates, and the citations that do not.
+func Citations(answer string, lineCounts map[string]int) ([]Citation, []string) {
+	var valid []Citation
+	var invalid []string
+	seen := make(map[string]bool)
+	for _, match := range citationPattern.FindAllStringSubmatch(answer, -1) {
+		if seen[match[0]] {
+			continue
+		}
+		seen[match[0]] = true
+		lineCount, ok := lineCounts[match[1]]
+		if !ok {
+			// Text like "localhost:8080" is not a citation.
+			continue
+		}
+		citation := Citation{Path: match[1]}
+		citation.StartLine, _ = strconv.Atoi(match[2])
+		citation.EndLine = citation.StartLine
+		if match[3] != "" {
+			citation.EndLine, _ = strconv.Atoi(match[3])
+		}
+		if citation.StartLine < 1 || citation.EndLine < citation.StartLine || citation.EndLine > lineCount {
+			invalid = append(invalid, match[0])
+			continue
+		}
+		valid = append(valid, citation)
+	}
+	sort.Slice(valid, func(i, j int) bool {
+		if valid[i].Path != valid[j].Path {
+			return valid[i].Path < valid[j].Path
+		}
+		if valid[i].StartLine != valid[j].StartLine {
+			return valid[i].StartLine < valid[j].StartLine
+		}
+		return valid[i].EndLine < valid[j].EndLine
+	})
+	return slices.Compact(valid), invalid
+}
@@ -0,0 +1,130 @@
+// Package baseline records the findings of a review that have been accepted, so later
+// reviews only report new findings. Findings are recognized by a fingerprint of the code
+// around them rather than their line numbers, so they survive unrelated edits.
+package baseline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/vossenwout/crev/pkg/review"
+)
+
+// DefaultFile is the file the baseline is written to unless another one is given.
+const DefaultFile = ".crev-baseline.json"
+
+// Version of the baseline file format.
+const version = 1
+
+// Number of lines above and below a finding that are part of its fingerprint.
+const contextLines = 2
+
+// Entry is an accepted finding. Everything except the fingerprint is only kept to make
+// the file readable.
+type Entry struct {
+	Fingerprint string          `json:"fingerprint"`
+	Path        string          `json:"path"`
+	Severity    review.Severity `json:"severity"`
+	Category    string          `json:"category"`
+	Message     string          `json:"message"`
+}
+
+// Baseline is a set of accepted findings.
+type Baseline struct {
+	Version  int     `json:"version"`
+	Findings []Entry `json:"findings"`
+}
+
+// Fingerprint identifies a finding by its file, its category and the code it is about,
+// given the content of the file. The code is the lines of the finding and the lines
+// around it with whitespace normalized. Findings about a whole file or without content
+// are identified by their message instead.
+func Fingerprint(finding review.Finding, content string) string {
+	code := ""
+	if finding.StartLine > 0 && content != "" {
+		lines := strings.Split(content, "\n")
+		start := max(finding.StartLine-1-contextLines, 0)
+		end := min(max(finding.EndLine, finding.StartLine)+contextLines, len(lines))
+		if start < end {
+			code = normalize(strings.Join(lines[start:end], "\n"))
+		}
+	}
+	if code == "" {
+		code = "message:" + normalize(strings.ToLower(finding.Message))
+	}
+	hash := sha256.Sum256([]byte(finding.Path + "\x00" + strings.ToLower(finding.Category) + "\x00" + code))
+	return hex.EncodeToString(hash[:16])
+}
+
+// Returns the text with every run of whitespace replaced by a single space.
+func normalize(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// New returns a baseline that accepts the findings, which must have a fingerprint.
+func New(findings []review.Finding) *Baseline {
+	b := &Baseline{Version: version, Findings: []Entry{}}
+	seen := make(map[string]bool)
+	for _, finding := range findings {
+		if seen[finding.Fingerprint] {
+			continue
+		}
+		seen[finding.Fingerprint] = true
+		b.Findings = append(b.Findings, Entry{
+			Fingerprint: finding.Fingerprint,
+			Path:        finding.Path,
+			Severity:    finding.Severity,
+			Category:    finding.Category,
+			Message:     finding.Message,
+		})
+	}
+	return b
+}
+
+// Load reads a baseline from a file.
+func Load(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading baseline: %w", err)
+	}
+	var b Baseline
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("error decoding baseline %s: %w", path, err)
+	}
+	if b.Version != version {
+		return nil, fmt.Errorf("baseline %s has unsupported version %d", path, b.Version)
+	}
+	return &b, nil
+}
+
+// Save writes the baseline to a file.
+func (b *Baseline) Save(path string) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Filter returns the findings that are not in the baseline and the number of findings
+// that are.
+func (b *Baseline) Filter(findings []review.Finding) ([]review.Finding, int) {
+	accepted := make(map[string]bool, len(b.Findings))
+	for _, entry := range b.Findings {
+		accepted[entry.Fingerprint] = true
+	}
+	var result []review.Finding
+	suppressed := 0
+	for _, finding := range findings {
+		if accepted[finding.Fingerprint] {
+			suppressed++
+			continue
+		}
+		result = append(result, finding)
+	}
+	return result, suppressed
+}
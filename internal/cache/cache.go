@@ -0,0 +1,136 @@
+// Package cache stores the results of reviews on disk, so that reviewing code that has
+// not changed again returns the earlier result instead of sending the code once more.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cache is a directory with one JSON file per cached result.
+type Cache struct {
+	dir string
+}
+
+// New returns a cache that stores its entries in dir.
+func New(dir string) *Cache {
+	return &Cache{dir: dir}
+}
+
+// Default returns the cache in the user cache directory, which is $XDG_CACHE_HOME/crev/reviews
+// (~/.cache/crev/reviews by default) on Linux.
+func Default() (*Cache, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("error finding the cache directory: %w", err)
+	}
+	return New(filepath.Join(dir, "crev", "reviews")), nil
+}
+
+// Dir returns the directory of the cache.
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Key returns the key of the entry for the given parts, ex. the code, provider and model.
+// Every part is length prefixed, so moving text from one part to the next changes the key.
+func Key(parts ...string) string {
+	hash := sha256.New()
+	for _, part := range parts {
+		hash.Write([]byte(strconv.Itoa(len(part)) + ":" + part))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// Returns the path of the file of the entry with the key.
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// Get decodes the entry with the key into v and returns when it was stored. It returns
+// false if there is no such entry.
+func (c *Cache) Get(key string, v any) (time.Time, bool, error) {
+	path := c.path(key)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return time.Time{}, false, fmt.Errorf("error decoding cache entry %s: %w", path, err)
+	}
+	return info.ModTime(), true, nil
+}
+
+// Put stores v as JSON under the key. The entry is written to a temporary file first, so
+// concurrent runs never read half of an entry.
+func (c *Cache) Put(key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return fmt.Errorf("error creating cache directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(key))
+}
+
+// Prune removes the entries that were stored longer ago than maxAge and returns how
+// many were removed. A maxAge of zero removes all entries.
+func (c *Cache) Prune(maxAge time.Duration) (int, error) {
+	entries, err := os.ReadDir(c.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !(strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".tmp")) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return removed, err
+		}
+		if maxAge > 0 && info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.dir, name)); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
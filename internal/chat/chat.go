@@ -0,0 +1,172 @@
+// Package chat holds a conversation with a chat model about a bundle and its review, so
+// that questions raised by the review can be asked after reading it.
+package chat
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/vossenwout/crev/internal/files"
+	reviewapi "github.com/vossenwout/crev/pkg/review"
+)
+
+// The instructions of the chat model, followed by the project and its review.
+const chatPrompt = `You are an experienced software engineer who reviewed a project. The project and your review
+of it follow. Answer the questions of the user about the code and the review. Refer to files by their path and
+to lines where you can, and say so when the answer depends on code you have not been given.`
+
+// Session is a conversation about a bundle and its review.
+type Session struct {
+	provider reviewapi.ChatProvider
+	bundle   string
+	review   string
+	// Bundles of the files added during the conversation.
+	added      []string
+	messages   []reviewapi.Message
+	transcript strings.Builder
+}
+
+// NewSession starts a conversation about the bundle and its review. The review can be empty.
+func NewSession(provider reviewapi.ChatProvider, bundle string, review string) *Session {
+	s := &Session{provider: provider, bundle: bundle, review: review}
+	s.transcript.WriteString("# crev chat\n")
+	return s
+}
+
+// System returns the system prompt with the project, its review and the added files.
+func (s *Session) System() string {
+	var sb strings.Builder
+	sb.WriteString(chatPrompt + "\n\n# Project\n\n" + s.bundle)
+	if s.review != "" {
+		sb.WriteString("\n\n# Review\n\n" + s.review)
+	}
+	for _, added := range s.added {
+		sb.WriteString("\n\n# Files added during the conversation\n\n" + added)
+	}
+	return sb.String()
+}
+
+// Add adds a bundle of more files to the context of the conversation.
+func (s *Session) Add(bundle string, description string) {
+	s.added = append(s.added, bundle)
+	fmt.Fprintf(&s.transcript, "\n*Added %s to the conversation.*\n", description)
+}
+
+// Messages returns the questions and answers of the conversation.
+func (s *Session) Messages() []reviewapi.Message {
+	return s.messages
+}
+
+// Ask sends the question with the conversation so far and returns the answer, which is
+// streamed to w if it is not nil. A question that fails is not kept in the conversation.
+func (s *Session) Ask(ctx context.Context, question string, w io.Writer) (string, error) {
+	messages := append(s.messages, reviewapi.Message{Role: "user", Content: question})
+	answer, err := s.provider.Chat(ctx, s.System(), messages, w)
+	if err != nil {
+		return answer, err
+	}
+	s.messages = append(messages, reviewapi.Message{Role: "assistant", Content: answer})
+	fmt.Fprintf(&s.transcript, "\n## You\n\n%s\n\n## crev\n\n%s\n", question, strings.TrimSpace(answer))
+	return answer, nil
+}
+
+// Transcript returns the conversation in markdown.
+func (s *Session) Transcript() string {
+	return s.transcript.String()
+}
+
+// REPL reads questions and commands from In and writes the answers to Out.
+type REPL struct {
+	Session *Session
+	In      io.Reader
+	Out     io.Writer
+	// Load returns the bundle of the files at the path, or of the files in it if it is a
+	// directory, and a description of what was loaded.
+	Load func(path string) (string, string, error)
+	// TranscriptFile is where the transcript is saved after every answer. Empty does not save it.
+	TranscriptFile string
+}
+
+// Help lists the commands of the REPL.
+const Help = `Ask a question about the code or the review, or use one of the commands:
+  /add <path>  add a file or directory to the conversation
+  /help        show this help
+  /exit        end the conversation (or press ctrl+d)
+`
+
+// Run reads questions until the input ends or the user exits. Pressing ctrl+c while an
+// answer is written stops that answer.
+func (r *REPL) Run() error {
+	reader := bufio.NewReader(r.In)
+	for {
+		fmt.Fprint(r.Out, "\n> ")
+		line, err := reader.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if errors.Is(err, io.EOF) && line == "" {
+			fmt.Fprintln(r.Out)
+			return nil
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+			continue
+		case line == "/exit" || line == "/quit":
+			return nil
+		case line == "/help":
+			fmt.Fprint(r.Out, Help)
+		case line == "/add" || strings.HasPrefix(line, "/add "):
+			r.add(strings.TrimSpace(strings.TrimPrefix(line, "/add")))
+		case strings.HasPrefix(line, "/"):
+			fmt.Fprintf(r.Out, "Unknown command %s\n%s", strings.Fields(line)[0], Help)
+		default:
+			r.ask(line)
+		}
+	}
+}
+
+// Adds the files at the path to the conversation.
+func (r *REPL) add(path string) {
+	if path == "" {
+		fmt.Fprintln(r.Out, "Usage: /add <path>")
+		return
+	}
+	bundle, description, err := r.Load(path)
+	if err != nil {
+		fmt.Fprintf(r.Out, "Could not add %s: %v\n", path, err)
+		return
+	}
+	r.Session.Add(bundle, description)
+	fmt.Fprintf(r.Out, "Added %s to the conversation.\n", description)
+	r.save()
+}
+
+// Asks the question and shows the answer while it is written.
+func (r *REPL) ask(question string) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	_, err := r.Session.Ask(ctx, question, r.Out)
+	fmt.Fprintln(r.Out)
+	if err != nil {
+		fmt.Fprintf(r.Out, "Failed to answer: %v\n", err)
+		return
+	}
+	r.save()
+}
+
+// Saves the transcript, if a file is set.
+func (r *REPL) save() {
+	if r.TranscriptFile == "" {
+		return
+	}
+	if err := files.SaveStringToFile(r.Session.Transcript(), r.TranscriptFile); err != nil {
+		fmt.Fprintf(r.Out, "Error saving the transcript: %v\n", err)
+	}
+}
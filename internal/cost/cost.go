@@ -0,0 +1,169 @@
+// Package cost estimates the tokens and the price of a review before it is sent.
+package cost
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/vossenwout/crev/internal/tokens"
+	"github.com/vossenwout/crev/pkg/review"
+)
+
+// Price is the price of a model in US dollars per million tokens.
+type Price struct {
+	Input  float64 `mapstructure:"input"`
+	Output float64 `mapstructure:"output"`
+}
+
+// PriceRule sets the price of the models of a provider that match a pattern, as
+// configured under prices in .crev-config.yaml.
+type PriceRule struct {
+	Provider string `mapstructure:"provider"`
+	// Model is a model name or a pattern like "gpt-4o*".
+	Model  string  `mapstructure:"model"`
+	Input  float64 `mapstructure:"input"`
+	Output float64 `mapstructure:"output"`
+}
+
+// DefaultPrices are the list prices of common models. Configured rules take precedence,
+// so prices that have changed can be corrected without a new release.
+var DefaultPrices = []PriceRule{
+	{Provider: "openai", Model: "gpt-4o-mini*", Input: 0.15, Output: 0.60},
+	{Provider: "openai", Model: "gpt-4o*", Input: 2.50, Output: 10},
+	{Provider: "openai", Model: "gpt-4.1-nano*", Input: 0.10, Output: 0.40},
+	{Provider: "openai", Model: "gpt-4.1-mini*", Input: 0.40, Output: 1.60},
+	{Provider: "openai", Model: "gpt-4.1*", Input: 2, Output: 8},
+	{Provider: "openai", Model: "o3-mini*", Input: 1.10, Output: 4.40},
+	{Provider: "anthropic", Model: "claude-3-5-haiku*", Input: 0.80, Output: 4},
+	{Provider: "anthropic", Model: "claude-3-5-sonnet*", Input: 3, Output: 15},
+	{Provider: "anthropic", Model: "claude-3-7-sonnet*", Input: 3, Output: 15},
+	{Provider: "anthropic", Model: "claude-3-opus*", Input: 15, Output: 75},
+	// Local models are free.
+	{Provider: "ollama", Model: "*"},
+}
+
+// Lookup returns the price of the model of the provider from the first matching rule of
+// the configured rules, then the default prices. It returns false if the price is unknown.
+func Lookup(provider string, model string, rules []PriceRule) (Price, bool) {
+	for _, rule := range slices.Concat(rules, DefaultPrices) {
+		if !strings.EqualFold(rule.Provider, provider) {
+			continue
+		}
+		if matched, _ := path.Match(strings.ToLower(rule.Model), strings.ToLower(model)); matched {
+			return Price{Input: rule.Input, Output: rule.Output}, true
+		}
+	}
+	return Price{}, false
+}
+
+// Reviews are estimated to be a tenth of the size of the code, within these bounds.
+const (
+	minOutputTokens = 1000
+	maxOutputTokens = 8192
+)
+
+// Returns the estimated number of output tokens of a review of code of the given size.
+func outputTokens(inputTokens int) int {
+	return min(max(inputTokens/10, minOutputTokens), maxOutputTokens)
+}
+
+// Estimate is the expected size and price of a review.
+type Estimate struct {
+	Provider string
+	Model    string
+	// Requests is the number of requests, more than one when the code is reviewed in chunks.
+	Requests       int
+	MinInputTokens int
+	MaxInputTokens int
+	OutputTokens   int
+	// Price is the price of the model, if it is known.
+	Price      Price
+	KnownPrice bool
+}
+
+// Options describes how the code will be reviewed.
+type Options struct {
+	Provider string
+	Model    string
+	// Instructions are sent with every request.
+	Instructions string
+	// ChunkTokens is the size above which code is reviewed in chunks. Zero never chunks.
+	ChunkTokens int
+	// Combine is set when the provider combines the reviews of chunks in a request of its
+	// own, as chat models do. Otherwise the reviews are put one after the other.
+	Combine bool
+	// Prices are the configured price rules.
+	Prices []PriceRule
+}
+
+// New estimates the tokens and price of a review of the code. Code that is reviewed in
+// chunks needs a request per chunk and, if the provider combines them, one to combine
+// their reviews.
+func New(codeToReview string, opts Options) Estimate {
+	estimate := Estimate{Provider: opts.Provider, Model: opts.Model, Requests: 1}
+	estimate.Price, estimate.KnownPrice = Lookup(opts.Provider, opts.Model, opts.Prices)
+	minInstructions, maxInstructions := tokens.Range(opts.Instructions)
+
+	minTokens, maxTokens := tokens.Range(codeToReview)
+	var chunks []review.Chunk
+	if opts.ChunkTokens > 0 && maxTokens > opts.ChunkTokens {
+		chunks = review.SplitBundle(codeToReview, opts.ChunkTokens)
+	}
+	if len(chunks) <= 1 {
+		estimate.MinInputTokens = minTokens + minInstructions
+		estimate.MaxInputTokens = maxTokens + maxInstructions
+		estimate.OutputTokens = outputTokens(maxTokens)
+		return estimate
+	}
+	var chunkOutputs int
+	estimate.Requests = len(chunks)
+	for _, chunk := range chunks {
+		minChunk, maxChunk := tokens.Range(chunk.Bundle)
+		estimate.MinInputTokens += minChunk + minInstructions
+		estimate.MaxInputTokens += maxChunk + maxInstructions
+		chunkOutputs += outputTokens(maxChunk)
+	}
+	estimate.OutputTokens = chunkOutputs
+	if !opts.Combine {
+		return estimate
+	}
+	// The reviews of the chunks are sent once more to be combined.
+	estimate.Requests++
+	estimate.MinInputTokens += chunkOutputs
+	estimate.MaxInputTokens += chunkOutputs
+	estimate.OutputTokens = chunkOutputs + outputTokens(chunkOutputs)
+	return estimate
+}
+
+// MinCost returns the expected price in US dollars for the lower estimate of input tokens.
+func (e Estimate) MinCost() float64 {
+	return (float64(e.MinInputTokens)*e.Price.Input + float64(e.OutputTokens)*e.Price.Output) / 1e6
+}
+
+// MaxCost returns the expected price in US dollars for the upper estimate of input tokens.
+func (e Estimate) MaxCost() float64 {
+	return (float64(e.MaxInputTokens)*e.Price.Input + float64(e.OutputTokens)*e.Price.Output) / 1e6
+}
+
+// String renders the estimate as shown to the user.
+func (e Estimate) String() string {
+	var sb strings.Builder
+	sb.WriteString("Estimated cost of the review:\n")
+	model := e.Model
+	if model == "" {
+		model = "selected by the service"
+	}
+	fmt.Fprintf(&sb, "  %-18s %s (%s)\n", "Provider:", e.Provider, model)
+	fmt.Fprintf(&sb, "  %-18s %d\n", "Requests:", e.Requests)
+	fmt.Fprintf(&sb, "  %-18s %d - %d\n", "Input tokens:", e.MinInputTokens, e.MaxInputTokens)
+	fmt.Fprintf(&sb, "  %-18s ~%d\n", "Output tokens:", e.OutputTokens)
+	if !e.KnownPrice {
+		fmt.Fprintf(&sb, "  %-18s unknown, set the price of the model under prices in your .crev-config.yaml\n", "Cost:")
+		return sb.String()
+	}
+	fmt.Fprintf(&sb, "  %-18s $%.2f - $%.2f (at $%g / $%g per million input / output tokens)\n", "Cost:",
+		e.MinCost(), e.MaxCost(), e.Price.Input, e.Price.Output)
+	return sb.String()
+}
@@ -0,0 +1,239 @@
+// Package fix asks a chat model for patches that fix the findings of a review and applies
+// them with git, only to the files of the bundle.
+package fix
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	reviewapi "github.com/vossenwout/crev/pkg/review"
+)
+
+// The instructions of the model, which receives the findings and the files they are in.
+const prompt = `You are an experienced software engineer who fixes issues found in a code review. The user
+sends the issues and the current content of the files they are in.
+
+For every issue, write a patch that fixes it in a fenced code block with the language diff, in the order of the
+issues. Patches are unified diffs with --- a/<path> and +++ b/<path> headers, using the paths as they are given,
+and hunks with three lines of context that match the current content of the files exactly. Only change the
+files you are given, do not create, delete or rename files. If an issue cannot be fixed with a patch, explain
+why in one sentence instead of writing a patch.`
+
+// Matches a fenced block with a patch.
+var patchPattern = regexp.MustCompile("(?s)```(?:diff|patch)[ \t]*\n(.*?)```")
+
+// Patch is a unified diff returned by the model.
+type Patch struct {
+	// Diff is the patch, with a/ and b/ prefixes on the paths.
+	Diff string
+	// Paths are the files the patch changes.
+	Paths []string
+}
+
+// Request returns the system prompt and the message that ask for patches for the findings,
+// given the content of the files they are in.
+func Request(findings []reviewapi.Finding, contents map[string]string) (string, string) {
+	var sb strings.Builder
+	sb.WriteString("# Issues\n\n")
+	for i, finding := range findings {
+		fmt.Fprintf(&sb, "%d. %s", i+1, finding)
+		if finding.Suggestion != "" {
+			sb.WriteString("\n   Suggestion: " + finding.Suggestion)
+		}
+		sb.WriteString("\n")
+	}
+	paths := make([]string, 0, len(contents))
+	for path := range contents {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	sb.WriteString("\n# Files\n")
+	for _, path := range paths {
+		fmt.Fprintf(&sb, "\n## %s\n\n```\n%s\n```\n", path, strings.TrimSuffix(contents[path], "\n"))
+	}
+	return prompt, sb.String()
+}
+
+// ExtractPatches returns the patches in the answer of the model. Patches whose paths are
+// not files of the bundle, or that create, delete or rename files, are returned as errors.
+func ExtractPatches(answer string, allowed map[string]bool) ([]Patch, []error) {
+	var patches []Patch
+	var errs []error
+	for i, match := range patchPattern.FindAllStringSubmatch(answer, -1) {
+		patch, err := parsePatch(match[1], allowed)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("patch %d: %w", i+1, err))
+			continue
+		}
+		patches = append(patches, patch)
+	}
+	return patches, errs
+}
+
+// Normalizes the headers of a patch to a/ and b/ prefixes and checks that it only changes
+// files of the bundle.
+func parsePatch(diff string, allowed map[string]bool) (Patch, error) {
+	var patch Patch
+	var lines []string
+	var oldPath string
+	diffLines := strings.Split(strings.TrimRight(diff, "\n"), "\n")
+	for i, line := range diffLines {
+		// A removed line can also start with "--- ", but a header is followed by "+++ ".
+		isOldHeader := strings.HasPrefix(line, "--- ") && i+1 < len(diffLines) && strings.HasPrefix(diffLines[i+1], "+++ ")
+		isNewHeader := strings.HasPrefix(line, "+++ ") && i > 0 && strings.HasPrefix(diffLines[i-1], "--- ")
+		switch {
+		case strings.HasPrefix(line, "diff ") || strings.HasPrefix(line, "index "):
+			// git reads the paths from the ---/+++ headers, which are checked below.
+			continue
+		case strings.HasPrefix(line, "rename ") || strings.HasPrefix(line, "copy ") ||
+			strings.HasPrefix(line, "new ") || strings.HasPrefix(line, "deleted ") ||
+			strings.HasPrefix(line, "old mode") || strings.HasPrefix(line, "similarity "):
+			return Patch{}, errors.New("patches may only change the content of existing files")
+		case isOldHeader:
+			oldPath = headerPath(line[4:], "a/")
+			line = "--- a/" + oldPath
+		case isNewHeader:
+			newPath := headerPath(line[4:], "b/")
+			if oldPath == "" || newPath != oldPath {
+				return Patch{}, fmt.Errorf("patches may only change the content of existing files, got %s", newPath)
+			}
+			if !allowed[newPath] {
+				return Patch{}, fmt.Errorf("%s is not one of the files sent to fix", newPath)
+			}
+			line = "+++ b/" + newPath
+			patch.Paths = append(patch.Paths, newPath)
+			oldPath = ""
+		}
+		lines = append(lines, line)
+	}
+	if len(patch.Paths) == 0 {
+		return Patch{}, errors.New("no files are changed")
+	}
+	patch.Diff = strings.Join(lines, "\n") + "\n"
+	return patch, nil
+}
+
+// Returns the path in a ---/+++ header without the prefix and any timestamp.
+func headerPath(header string, prefix string) string {
+	header, _, _ = strings.Cut(header, "\t")
+	header = strings.TrimSpace(header)
+	if header == "/dev/null" {
+		return header
+	}
+	return path.Clean(strings.TrimPrefix(header, prefix))
+}
+
+// Options configures how patches are checked and applied.
+type Options struct {
+	// Dir is the directory the paths of the patches are relative to.
+	Dir string
+}
+
+// Check returns an error if the patch does not apply cleanly to the working tree.
+func Check(ctx context.Context, patch Patch, opts Options) error {
+	return gitApply(ctx, patch, opts, "--check")
+}
+
+// Apply applies the patch to the working tree. It changes nothing if any hunk fails.
+func Apply(ctx context.Context, patch Patch, opts Options) error {
+	return gitApply(ctx, patch, opts)
+}
+
+// Runs git apply on the patch. Line counts of hunks written by models are often wrong,
+// so they are recounted.
+func gitApply(ctx context.Context, patch Patch, opts Options, args ...string) error {
+	args = append([]string{"apply", "--recount"}, args...)
+	cmd := exec.CommandContext(ctx, "git", append(args, "-")...)
+	cmd.Dir = opts.Dir
+	cmd.Stdin = strings.NewReader(patch.Diff)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return errors.New(msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// ANSI escape codes of the colors of the preview.
+const (
+	bold  = "\033[1m"
+	red   = "\033[31m"
+	green = "\033[32m"
+	cyan  = "\033[36m"
+	reset = "\033[0m"
+)
+
+// Colorize returns the patch with the colors of git diff: headers in bold, hunk headers
+// in cyan, removed lines in red and added lines in green.
+func Colorize(diff string) string {
+	var sb strings.Builder
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		if line == "" {
+			continue
+		}
+		text := strings.TrimSuffix(line, "\n")
+		color := ""
+		switch {
+		case strings.HasPrefix(text, "--- ") || strings.HasPrefix(text, "+++ ") || strings.HasPrefix(text, "diff "):
+			color = bold
+		case strings.HasPrefix(text, "@@"):
+			color = cyan
+		case strings.HasPrefix(text, "-"):
+			color = red
+		case strings.HasPrefix(text, "+"):
+			color = green
+		}
+		if color == "" {
+			sb.WriteString(line)
+			continue
+		}
+		sb.WriteString(color + text + reset + strings.TrimPrefix(line, text))
+	}
+	return sb.String()
+}
+
+// ParseSelection returns the indexes of the findings selected by their numbers, starting
+// at 1, separated by commas or spaces. Ranges like "2-4" and "all" select several findings.
+func ParseSelection(selection string, count int) ([]int, error) {
+	selected := make(map[int]bool)
+	for _, field := range strings.FieldsFunc(selection, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if field == "all" {
+			for i := range count {
+				selected[i] = true
+			}
+			continue
+		}
+		first, last, isRange := strings.Cut(field, "-")
+		start, err := strconv.Atoi(first)
+		end := start
+		if err == nil && isRange {
+			end, err = strconv.Atoi(last)
+		}
+		if err != nil || start < 1 || end < start || end > count {
+			return nil, fmt.Errorf("invalid selection %q, select findings by their number from 1 to %d", field, count)
+		}
+		for i := start; i <= end; i++ {
+			selected[i-1] = true
+		}
+	}
+	if len(selected) == 0 {
+		return nil, errors.New("no findings selected")
+	}
+	indexes := make([]int, 0, len(selected))
+	for i := range selected {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	return indexes, nil
+}
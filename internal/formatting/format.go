@@ -2,9 +2,11 @@
 package formatting
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -53,6 +55,27 @@ func GeneratePathTree(paths []string) string {
 	return treeBuilder.String()
 }
 
+// Given a list of file paths, TreePaths returns the paths together with the paths of their
+// parent directories, as GeneratePathTree expects them.
+func TreePaths(filePaths []string) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, path := range filePaths {
+		for dir := filepath.Dir(path); dir != "." && dir != string(os.PathSeparator); dir = filepath.Dir(dir) {
+			if seen[dir] {
+				break
+			}
+			seen[dir] = true
+			paths = append(paths, dir)
+		}
+		if !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
 // Creates a string representation of the project.
 func CreateProjectString(projectTree string, fileContentMap map[string]string) string {
 	var projectString strings.Builder
@@ -75,3 +98,60 @@ func CreateProjectString(projectTree string, fileContentMap map[string]string) s
 	}
 	return projectString.String()
 }
+
+// Header that precedes the path of every file in a project string.
+const fileHeader = "File: \n"
+
+// Given a project string created by CreateProjectString, ParseProjectString returns
+// the map of file paths to their content it was created from.
+func ParseProjectString(projectString string) map[string]string {
+	fileContentMap := make(map[string]string)
+	// Every file section starts at a line "File: " followed by the path and "Content: ".
+	var starts []int
+	for offset := 0; offset < len(projectString); {
+		i := strings.Index(projectString[offset:], fileHeader)
+		if i < 0 {
+			break
+		}
+		start := offset + i
+		if (start == 0 || projectString[start-1] == '\n') && isFileSection(projectString[start:]) {
+			starts = append(starts, start)
+		}
+		offset = start + len(fileHeader)
+	}
+	for i, start := range starts {
+		end := len(projectString)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		section := projectString[start+len(fileHeader) : end]
+		path, rest, _ := strings.Cut(section, "\n")
+		content := strings.TrimPrefix(rest, "Content: \n")
+		fileContentMap[path] = strings.TrimSuffix(content, "\n\n")
+	}
+	return fileContentMap
+}
+
+// Returns true if the text starts with the header, path and content marker of a file section.
+func isFileSection(text string) bool {
+	_, rest, found := strings.Cut(strings.TrimPrefix(text, fileHeader), "\n")
+	return found && strings.HasPrefix(rest, "Content: \n")
+}
+
+// NumberLines prefixes every line of the content with its number, so lines can be referred to.
+func NumberLines(content string) string {
+	lines := strings.Split(content, "\n")
+	// A final newline does not start another line.
+	if len(lines) > 1 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	width := len(strconv.Itoa(len(lines)))
+	var sb strings.Builder
+	for i, line := range lines {
+		fmt.Fprintf(&sb, "%*d | %s\n", width, i+1, line)
+	}
+	if !strings.HasSuffix(content, "\n") {
+		return strings.TrimSuffix(sb.String(), "\n")
+	}
+	return sb.String()
+}
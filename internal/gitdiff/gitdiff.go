@@ -0,0 +1,313 @@
+// Package gitdiff builds review payloads from the local changes in a git repository,
+// so a small change can be reviewed without sending the whole project.
+package gitdiff
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/vossenwout/crev/internal/formatting"
+	"github.com/vossenwout/crev/internal/secrets"
+)
+
+// ErrNoChanges is returned when there are no changes to review.
+var ErrNoChanges = errors.New("no changes found")
+
+// Options configures which changes are included in a payload and how much context they get.
+type Options struct {
+	// Dir is a directory in the repository, defaults to the current directory.
+	Dir string
+	// Base is the ref the working tree is compared to, defaults to HEAD.
+	Base string
+	// ContextLines is the number of unchanged lines shown around every hunk.
+	ContextLines int
+	// MaxFullFileLines includes touched files with at most this many lines in full.
+	// Zero never includes full files.
+	MaxFullFileLines int
+	// IgnorePrefixes, IgnoreExtensions and IncludeExtensions filter the changed files
+	// like they filter the files of a bundle.
+	IgnorePrefixes    []string
+	IgnoreExtensions  []string
+	IncludeExtensions []string
+	// Redact replaces personal data in the content of the changed files, like the redaction
+	// rules of a bundle. It is applied before secrets are redacted. Nil only redacts secrets.
+	Redact func(fileContentMap map[string]string) map[string]string
+}
+
+// Change is a changed file.
+type Change struct {
+	// Path is the path of the file relative to the root of the repository.
+	Path string
+	// Diff contains the hunks of the change with their surrounding context.
+	Diff string
+	// Content is the full content of the file after the change, if it is small enough.
+	Content string
+	// Text content of the file after the change, regardless of its size.
+	text string
+	// Untracked is true for new files that have not been added to git yet.
+	Untracked bool
+	// Deleted is true for files that no longer exist after the change.
+	Deleted bool
+	// Lines is the number of lines of the file after the change.
+	Lines int
+}
+
+// Payload is the code to review built from the changes.
+type Payload struct {
+	// Text is the code to review in the format of a crev bundle.
+	Text string
+	// LineCounts is the number of lines of every changed file after the change, which
+	// the line numbers of findings refer to.
+	LineCounts map[string]int
+	// Files is the redacted content of every changed file after the change, except for
+	// deleted and binary files.
+	Files map[string]string
+	// Secrets lists the secrets that have been redacted. Their lines refer to the file
+	// sections of the payload, not to the changed files.
+	Secrets []secrets.Finding
+}
+
+// Framing at the start of every payload so the reviewer focuses on the change.
+const promptFraming = `This is not a complete project, it is a change to a project (compared to %s).
+Review the change: focus on bugs, regressions, security issues, missing tests and unclear code
+introduced by the added and removed lines. The unchanged lines around every hunk and the full
+content of small files are only included as context, do not review them on their own.
+Line numbers of findings refer to the lines of the files after the change, as numbered in the hunk headers.
+
+`
+
+// Changes returns the files that differ between the working tree and the commit where
+// the current branch forked from the base ref, including untracked files, ordered by path.
+func Changes(ctx context.Context, opts Options) ([]Change, error) {
+	base := opts.Base
+	if base == "" {
+		base = "HEAD"
+	}
+	root, err := git(ctx, opts.Dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	root = strings.TrimSpace(root)
+	if _, err := git(ctx, root, "rev-parse", "--verify", "--quiet", base+"^{commit}"); err != nil {
+		return nil, fmt.Errorf("unknown base ref %q: %w", base, err)
+	}
+	// Compare to the point where the current branch forked from the base, so changes
+	// made on the base since then are not shown as reverted.
+	from, err := git(ctx, root, "merge-base", base, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	from = strings.TrimSpace(from)
+
+	// -z keeps paths with special characters intact.
+	nameStatus, err := git(ctx, root, "diff", "--name-status", "--no-renames", "-z", from, "--")
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	fields := strings.Split(strings.TrimSuffix(nameStatus, "\x00"), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		changes = append(changes, Change{Path: fields[i+1], Deleted: fields[i] == "D"})
+	}
+	untracked, err := git(ctx, root, "ls-files", "--others", "--exclude-standard", "-z")
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range strings.Split(strings.TrimSuffix(untracked, "\x00"), "\x00") {
+		if p != "" {
+			changes = append(changes, Change{Path: p, Untracked: true})
+		}
+	}
+
+	var result []Change
+	for _, change := range changes {
+		if !opts.keep(change.Path) {
+			continue
+		}
+		if !change.Untracked {
+			change.Diff, err = git(ctx, root, "diff", "--no-color", "--no-ext-diff", "--no-renames",
+				fmt.Sprintf("--unified=%d", opts.ContextLines), from, "--", change.Path)
+			if err != nil {
+				return nil, err
+			}
+			change.Diff = hunks(change.Diff)
+		}
+		if !change.Deleted {
+			content, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(change.Path)))
+			if err != nil {
+				return nil, err
+			}
+			change.Lines = lineCount(content)
+			if !bytes.Contains(content, []byte{0}) {
+				change.text = string(content)
+			}
+			// New files are entirely part of the change, so they are always included in full.
+			if change.Untracked || change.Lines <= opts.MaxFullFileLines {
+				change.Content = change.text
+			}
+		}
+		result = append(result, change)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Path < result[j].Path })
+	return result, nil
+}
+
+// Build returns the changes in the format of a crev bundle, preceded by instructions to
+// focus on the change. Every changed file is a file section with its hunks and, if small
+// enough, its full content. Personal data is redacted with opts.Redact, then secrets are
+// redacted.
+func Build(ctx context.Context, opts Options) (*Payload, error) {
+	changes, err := Changes(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 {
+		return nil, ErrNoChanges
+	}
+
+	fileContentMap := make(map[string]string, len(changes))
+	lineCounts := make(map[string]int, len(changes))
+	paths := make([]string, 0, len(changes))
+	for _, change := range changes {
+		paths = append(paths, filepath.FromSlash(change.Path))
+		fileContentMap[change.Path] = change.section()
+		lineCounts[change.Path] = change.Lines
+	}
+	files := make(map[string]string, len(changes))
+	for _, change := range changes {
+		if change.text != "" {
+			files[change.Path] = change.text
+		}
+	}
+	if opts.Redact != nil {
+		fileContentMap = opts.Redact(fileContentMap)
+		files = opts.Redact(files)
+	}
+	fileContentMap, findings := secrets.Redact(fileContentMap)
+	files, _ = secrets.Redact(files)
+
+	base := opts.Base
+	if base == "" {
+		base = "HEAD"
+	}
+	projectString := formatting.CreateProjectString(formatting.GeneratePathTree(formatting.TreePaths(paths)), fileContentMap)
+	return &Payload{
+		Text:       fmt.Sprintf(promptFraming, base) + projectString,
+		LineCounts: lineCounts,
+		Files:      files,
+		Secrets:    findings,
+	}, nil
+}
+
+// Prefix returns the path of the directory relative to the root of its repository, with
+// a trailing slash, or an empty string if it is the root or not in a repository.
+func Prefix(ctx context.Context, dir string) string {
+	prefix, err := git(ctx, dir, "rev-parse", "--show-prefix")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(prefix)
+}
+
+// Commit returns the commit checked out in the repository of dir, or an empty string if
+// dir is not in a git repository or has no commits.
+func Commit(ctx context.Context, dir string) string {
+	commit, err := git(ctx, dir, "rev-parse", "HEAD")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(commit)
+}
+
+// Returns the content of the file section of the change.
+func (c Change) section() string {
+	var sb strings.Builder
+	switch {
+	case c.Untracked:
+		sb.WriteString("New file, not yet added to git.\n")
+	case c.Deleted:
+		sb.WriteString("Deleted file.\n")
+	}
+	if c.Diff != "" {
+		sb.WriteString("Changes:\n" + c.Diff)
+	}
+	if c.Content != "" {
+		if c.Diff != "" {
+			sb.WriteString("\n")
+		}
+		sb.WriteString("Full content after the change:\n" + c.Content)
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// Returns true if the path passes the prefix and extension filters of the options.
+func (opts Options) keep(p string) bool {
+	for _, part := range strings.Split(p, "/") {
+		for _, prefix := range opts.IgnorePrefixes {
+			if strings.HasPrefix(part, prefix) {
+				return false
+			}
+		}
+	}
+	ext := path.Ext(p)
+	for _, ignored := range opts.IgnoreExtensions {
+		if ext == ignored {
+			return false
+		}
+	}
+	if len(opts.IncludeExtensions) == 0 {
+		return true
+	}
+	for _, included := range opts.IncludeExtensions {
+		if ext == included {
+			return true
+		}
+	}
+	return false
+}
+
+// Strips the file header of a git diff, keeping only the hunks.
+func hunks(diff string) string {
+	if i := strings.Index(diff, "\n@@"); i >= 0 {
+		return diff[i+1:]
+	}
+	if strings.TrimSpace(diff) == "" {
+		return ""
+	}
+	// Binary files and mode changes have no hunks, keep the summary git gives.
+	lines := strings.Split(strings.TrimSpace(diff), "\n")
+	return lines[len(lines)-1] + "\n"
+}
+
+// Returns the number of lines of the content.
+func lineCount(content []byte) int {
+	n := bytes.Count(content, []byte("\n"))
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		n++
+	}
+	return n
+}
+
+// Runs git in the directory and returns its output.
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return string(out), nil
+}
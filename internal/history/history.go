@@ -0,0 +1,205 @@
+// Package history keeps every review in a local directory with the metadata of the run,
+// so that runs can be listed and their findings compared.
+package history
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vossenwout/crev/pkg/review"
+)
+
+// DefaultDir is the directory in which reviews are kept, in the current directory.
+const DefaultDir = ".crev-history"
+
+// Layout of the IDs of runs, derived from the time of the run.
+const idLayout = "20060102-150405"
+
+// Run is a review together with the metadata of the run that produced it.
+type Run struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	// Commit is the git commit that was checked out, if any.
+	Commit   string `json:"commit,omitempty"`
+	Provider string `json:"provider"`
+	// Model is the configured model, empty for the default model of the provider.
+	Model       string `json:"model,omitempty"`
+	Destination string `json:"destination"`
+	// BundleHash identifies the reviewed code.
+	BundleHash string           `json:"bundle_hash"`
+	Review     string           `json:"review"`
+	Findings   []review.Finding `json:"findings"`
+}
+
+// History is a directory with one JSON file per run.
+type History struct {
+	dir string
+}
+
+// New returns the history kept in dir.
+func New(dir string) *History {
+	return &History{dir: dir}
+}
+
+// Dir returns the directory of the history.
+func (h *History) Dir() string {
+	return h.dir
+}
+
+// BundleHash returns the hash that identifies the reviewed code.
+func BundleHash(code string) string {
+	hash := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(hash[:])
+}
+
+// Save adds the run to the history. A run without ID gets one derived from its time.
+func (h *History) Save(run *Run) error {
+	if err := os.MkdirAll(h.dir, 0o755); err != nil {
+		return fmt.Errorf("error creating history directory: %w", err)
+	}
+	if run.ID == "" {
+		run.ID = run.Time.Format(idLayout)
+		// Runs in the same second get a suffix.
+		for i := 2; ; i++ {
+			if _, err := os.Stat(h.path(run.ID)); errors.Is(err, fs.ErrNotExist) {
+				break
+			}
+			run.ID = fmt.Sprintf("%s-%d", run.Time.Format(idLayout), i)
+		}
+	}
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(h.path(run.ID), append(data, '\n'), 0644)
+}
+
+// Returns the path of the file of the run with the ID.
+func (h *History) path(id string) string {
+	return filepath.Join(h.dir, id+".json")
+}
+
+// List returns the runs in the history, oldest first.
+func (h *History) List() ([]*Run, error) {
+	entries, err := os.ReadDir(h.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var runs []*Run
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		run, err := h.load(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	sort.SliceStable(runs, func(i, j int) bool {
+		if !runs[i].Time.Equal(runs[j].Time) {
+			return runs[i].Time.Before(runs[j].Time)
+		}
+		return runs[i].ID < runs[j].ID
+	})
+	return runs, nil
+}
+
+// Reads the run with the ID.
+func (h *History) load(id string) (*Run, error) {
+	data, err := os.ReadFile(h.path(id))
+	if err != nil {
+		return nil, err
+	}
+	var run Run
+	if err := json.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("error decoding run %s: %w", id, err)
+	}
+	return &run, nil
+}
+
+// Get returns the run referred to by ref: its ID, a prefix of only its ID or "latest".
+func (h *History) Get(ref string) (*Run, error) {
+	runs, err := h.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) == 0 {
+		return nil, fmt.Errorf("there are no reviews in %s", h.dir)
+	}
+	if ref == "latest" {
+		return runs[len(runs)-1], nil
+	}
+	var matches []*Run
+	for _, run := range runs {
+		if run.ID == ref {
+			return run, nil
+		}
+		if strings.HasPrefix(run.ID, ref) {
+			matches = append(matches, run)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no review %q in %s", ref, h.dir)
+	case 1:
+		return matches[0], nil
+	}
+	return nil, fmt.Errorf("%q matches %d reviews, use a longer ID", ref, len(matches))
+}
+
+// Comparison are the findings of a run sorted by what became of them in a later run.
+type Comparison struct {
+	// Fixed are the findings of the first run that are not in the second.
+	Fixed []review.Finding
+	// Persisted are the findings of the second run that were in the first.
+	Persisted []review.Finding
+	// Introduced are the findings of the second run that were not in the first.
+	Introduced []review.Finding
+}
+
+// Compare matches the findings of two runs by their fingerprints, which stay the same as
+// long as the code around a finding does not change.
+func Compare(a *Run, b *Run) Comparison {
+	// Count the fingerprints, so a finding raised twice is only matched twice.
+	remaining := make(map[string]int)
+	for _, finding := range a.Findings {
+		remaining[key(finding)]++
+	}
+	var comparison Comparison
+	for _, finding := range b.Findings {
+		if remaining[key(finding)] > 0 {
+			remaining[key(finding)]--
+			comparison.Persisted = append(comparison.Persisted, finding)
+		} else {
+			comparison.Introduced = append(comparison.Introduced, finding)
+		}
+	}
+	for _, finding := range a.Findings {
+		if remaining[key(finding)] > 0 {
+			remaining[key(finding)]--
+			comparison.Fixed = append(comparison.Fixed, finding)
+		}
+	}
+	return comparison
+}
+
+// Returns the key by which findings are matched between runs.
+func key(finding review.Finding) string {
+	if finding.Fingerprint != "" {
+		return finding.Fingerprint
+	}
+	return finding.Path + "\x00" + strings.ToLower(finding.Category) + "\x00" + finding.Message
+}
@@ -0,0 +1,78 @@
+// Contains code to reduce Go source files to an outline of their declarations.
+package outline
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+)
+
+// goOutliner outlines Go files using the standard library parser.
+type goOutliner struct{}
+
+// Given the content of a Go file, Outline returns the package clause, imports,
+// type declarations and function signatures (with their doc comments) while
+// eliding all function bodies.
+func (goOutliner) Outline(path string, content string) (string, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, content, parser.ParseComments)
+	if err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+	writeDoc(&out, file.Doc)
+	out.WriteString("package " + file.Name.Name + "\n")
+
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.GenDecl:
+			if d.Tok != token.IMPORT && d.Tok != token.TYPE {
+				continue
+			}
+			out.WriteString("\n")
+			// Keep the comments inside the declaration, e.g. struct field docs.
+			node := &printer.CommentedNode{Node: d, Comments: commentsWithin(file, d)}
+			if err := printer.Fprint(&out, fset, node); err != nil {
+				return "", err
+			}
+			out.WriteString("\n")
+		case *ast.FuncDecl:
+			out.WriteString("\n")
+			writeDoc(&out, d.Doc)
+			signature := &ast.FuncDecl{Recv: d.Recv, Name: d.Name, Type: d.Type}
+			if err := printer.Fprint(&out, fset, signature); err != nil {
+				return "", err
+			}
+			out.WriteString("\n")
+		}
+	}
+	return out.String(), nil
+}
+
+// Writes the comment lines of a doc comment group as they appear in the source.
+func writeDoc(out *bytes.Buffer, doc *ast.CommentGroup) {
+	if doc == nil {
+		return
+	}
+	for _, comment := range doc.List {
+		out.WriteString(comment.Text + "\n")
+	}
+}
+
+// Returns the comment groups of the file that lie within the given declaration.
+func commentsWithin(file *ast.File, decl *ast.GenDecl) []*ast.CommentGroup {
+	start := decl.Pos()
+	if decl.Doc != nil {
+		start = decl.Doc.Pos()
+	}
+	var comments []*ast.CommentGroup
+	for _, group := range file.Comments {
+		if group.Pos() >= start && group.End() <= decl.End() {
+			comments = append(comments, group)
+		}
+	}
+	return comments
+}
@@ -0,0 +1,69 @@
+// Package outline reduces source files to an outline of their declarations, so that a
+// bundle describes the architecture of a project without every function body.
+package outline
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Outliner reduces the content of a source file to its declarations.
+type Outliner interface {
+	Outline(path string, content string) (string, error)
+}
+
+// OutlinerFunc allows an ordinary function to be used as an Outliner.
+type OutlinerFunc func(path string, content string) (string, error)
+
+// Outline calls f(path, content).
+func (f OutlinerFunc) Outline(path string, content string) (string, error) {
+	return f(path, content)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Outliner{}
+)
+
+func init() {
+	Register(".go", goOutliner{})
+	Register(".py", pythonOutliner{})
+	for _, ext := range []string{".ts", ".tsx", ".mts", ".cts", ".js", ".jsx", ".mjs", ".cjs"} {
+		Register(ext, scriptOutliner{})
+	}
+}
+
+// Register makes an outliner available for files with the given extension (ex. ".rs").
+// Registering an extension twice replaces the previous outliner.
+func Register(ext string, outliner Outliner) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[strings.ToLower(ext)] = outliner
+}
+
+// Lookup returns the outliner registered for the extension of the given path.
+func Lookup(path string) (Outliner, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	outliner, ok := registry[strings.ToLower(filepath.Ext(path))]
+	return outliner, ok
+}
+
+// Given a map of file paths to their content, ContentMap returns a new map in which
+// every supported file is replaced by its outline. Files in unsupported languages,
+// and files that fail to parse, keep their full content.
+func ContentMap(fileContentMap map[string]string) map[string]string {
+	outlined := make(map[string]string, len(fileContentMap))
+	for path, content := range fileContentMap {
+		outlined[path] = content
+		outliner, ok := Lookup(path)
+		if !ok {
+			continue
+		}
+		if result, err := outliner.Outline(path, content); err == nil {
+			outlined[path] = result
+		}
+	}
+	return outlined
+}
@@ -0,0 +1,216 @@
+// Contains code to reduce Python source files to an outline of their declarations.
+package outline
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	pythonDefRe    = regexp.MustCompile(`^(async\s+def|def)\s`)
+	pythonClassRe  = regexp.MustCompile(`^class\s`)
+	pythonImportRe = regexp.MustCompile(`^(import|from)\s`)
+	// Matches class attributes such as dataclass fields ("name: str = ''").
+	pythonAttrRe = regexp.MustCompile(`^[A-Za-z_]\w*\s*(:|=[^=])`)
+)
+
+// pythonOutliner outlines Python files by tracking indentation. It keeps imports,
+// decorators, classes, class attributes and function signatures together with
+// their docstrings, and replaces function bodies with "...".
+type pythonOutliner struct{}
+
+// A scope that has been opened by a kept class or def statement.
+type pythonScope struct {
+	indent int
+	isDef  bool
+}
+
+// Given the content of a Python file, Outline returns its outline.
+func (pythonOutliner) Outline(_ string, content string) (string, error) {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	var out strings.Builder
+	var scopes []pythonScope
+	// The quote of the multi-line string we are currently skipping, if any.
+	openQuote := ""
+
+	i := 0
+	// Keep the module docstring.
+	for i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+		i++
+	}
+	if i < len(lines) && startsDocstring(lines[i]) {
+		i = writeDocstring(&out, lines, i)
+	}
+
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		if openQuote != "" {
+			if strings.Count(line, openQuote)%2 == 1 {
+				openQuote = ""
+			}
+			continue
+		}
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := indentation(line)
+		for len(scopes) > 0 && indent <= scopes[len(scopes)-1].indent {
+			scopes = scopes[:len(scopes)-1]
+		}
+		// Inside a function body everything is elided.
+		if len(scopes) > 0 && scopes[len(scopes)-1].isDef {
+			openQuote = unterminatedQuote(line)
+			continue
+		}
+		inClass := len(scopes) > 0
+
+		switch {
+		case strings.HasPrefix(trimmed, "@"):
+			if out.Len() > 0 && (!inClass || precededByBlank(lines, i)) && !precededByDecorator(lines, i) {
+				out.WriteString("\n")
+			}
+			out.WriteString(line + "\n")
+		case pythonDefRe.MatchString(trimmed), pythonClassRe.MatchString(trimmed):
+			if out.Len() > 0 && (!inClass || precededByBlank(lines, i)) && !precededByDecorator(lines, i) {
+				out.WriteString("\n")
+			}
+			i = writeStatement(&out, lines, i)
+			isDef := pythonDefRe.MatchString(trimmed)
+			// A body on the same line as the signature ("def f(): return 1") is part of the
+			// statement and kept, only a body in a block of its own is elided.
+			hasBlock := strings.HasSuffix(strings.TrimSpace(stripPythonComment(lines[i])), ":")
+			if hasBlock && i+1 < len(lines) && startsDocstring(nextCodeLine(lines, i+1)) {
+				i = writeDocstring(&out, lines, skipBlank(lines, i+1))
+			}
+			if isDef && hasBlock {
+				out.WriteString(strings.Repeat(" ", indent+4) + "...\n")
+			}
+			scopes = append(scopes, pythonScope{indent: indent, isDef: isDef})
+		case !inClass && pythonImportRe.MatchString(trimmed):
+			i = writeStatement(&out, lines, i)
+		case inClass && pythonAttrRe.MatchString(trimmed):
+			i = writeStatement(&out, lines, i)
+		default:
+			// Skip other statements, including any multi-line strings they open.
+			openQuote = unterminatedQuote(line)
+		}
+	}
+	return out.String(), nil
+}
+
+// Writes the statement starting at line i, following open brackets and line
+// continuations, and returns the index of its last line.
+func writeStatement(out *strings.Builder, lines []string, i int) int {
+	depth := 0
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		out.WriteString(line + "\n")
+		code := stripPythonComment(line)
+		depth += strings.Count(code, "(") + strings.Count(code, "[") + strings.Count(code, "{")
+		depth -= strings.Count(code, ")") + strings.Count(code, "]") + strings.Count(code, "}")
+		if depth <= 0 && !strings.HasSuffix(strings.TrimSpace(code), "\\") {
+			return i
+		}
+	}
+	return len(lines) - 1
+}
+
+// Writes the docstring starting at line i and returns the index of its last line.
+func writeDocstring(out *strings.Builder, lines []string, i int) int {
+	quote := strings.TrimSpace(lines[i])
+	quote = strings.TrimLeft(quote, "rRbBuU")[:3]
+	out.WriteString(lines[i] + "\n")
+	if strings.Count(lines[i], quote) >= 2 {
+		return i
+	}
+	for i++; i < len(lines); i++ {
+		out.WriteString(lines[i] + "\n")
+		if strings.Contains(lines[i], quote) {
+			return i
+		}
+	}
+	return len(lines) - 1
+}
+
+// Returns true if the line starts a triple-quoted string.
+func startsDocstring(line string) bool {
+	trimmed := strings.TrimLeft(strings.TrimSpace(line), "rRbBuU")
+	return strings.HasPrefix(trimmed, `"""`) || strings.HasPrefix(trimmed, `'''`)
+}
+
+// Returns the triple quote the line leaves open, or "" if all strings are closed.
+func unterminatedQuote(line string) string {
+	for _, quote := range []string{`"""`, `'''`} {
+		if strings.Count(line, quote)%2 == 1 {
+			return quote
+		}
+	}
+	return ""
+}
+
+// Returns true if the line before i is a decorator.
+func precededByDecorator(lines []string, i int) bool {
+	return i > 0 && strings.HasPrefix(strings.TrimSpace(lines[i-1]), "@")
+}
+
+// Returns true if the line before i is blank.
+func precededByBlank(lines []string, i int) bool {
+	return i > 0 && strings.TrimSpace(lines[i-1]) == ""
+}
+
+// Returns the index of the first non-blank line starting from i.
+func skipBlank(lines []string, i int) int {
+	for i < len(lines)-1 && strings.TrimSpace(lines[i]) == "" {
+		i++
+	}
+	return i
+}
+
+// Returns the first non-blank line starting from i.
+func nextCodeLine(lines []string, i int) string {
+	return lines[skipBlank(lines, i)]
+}
+
+// Returns the number of leading whitespace characters, counting a tab as 4 spaces.
+func indentation(line string) int {
+	n := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			n++
+		case '\t':
+			n += 4
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+// Removes a trailing comment from a line of Python code, together with the content of
+// its string literals, so that a "#" or bracket in a string is not mistaken for code.
+func stripPythonComment(line string) string {
+	var code strings.Builder
+	quote := byte(0)
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+				code.WriteByte(c)
+			}
+		case c == '#':
+			return code.String()
+		case c == '\'' || c == '"':
+			quote = c
+			code.WriteByte(c)
+		default:
+			code.WriteByte(c)
+		}
+	}
+	return code.String()
+}
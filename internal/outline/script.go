@@ -0,0 +1,248 @@
+// Contains code to reduce TypeScript and JavaScript source files to an outline of their declarations.
+package outline
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	scriptImportRe   = regexp.MustCompile(`^import\b`)
+	scriptTypeDeclRe = regexp.MustCompile(`^(export\s+)?(default\s+)?(declare\s+)?(const\s+enum|enum|interface|type|namespace|module)\b`)
+	scriptFuncRe     = regexp.MustCompile(`^(export\s+)?(default\s+)?(declare\s+)?(async\s+)?function\b`)
+	scriptClassRe    = regexp.MustCompile(`^(export\s+)?(default\s+)?(declare\s+)?(abstract\s+)?class\b`)
+	scriptExportRe   = regexp.MustCompile(`^export\b`)
+)
+
+// scriptOutliner outlines TypeScript and JavaScript files by tracking braces. It keeps
+// imports, exports, type declarations, classes and function signatures together with
+// their doc comments, and replaces function bodies with "{ ... }".
+type scriptOutliner struct{}
+
+// Tracks the nesting of braces and parentheses outside of strings and comments.
+type scriptScanner struct {
+	braces         int
+	parens         int
+	inBlockComment bool
+	inTemplate     bool
+}
+
+// Scans a line, updating the nesting state, and returns the line with comments removed.
+func (s *scriptScanner) scan(line string) string {
+	var code strings.Builder
+	quote := byte(0)
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case s.inBlockComment:
+			if c == '*' && i+1 < len(line) && line[i+1] == '/' {
+				s.inBlockComment = false
+				i++
+			}
+			continue
+		case s.inTemplate:
+			if c == '\\' {
+				i++
+			} else if c == '`' {
+				s.inTemplate = false
+			}
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '/' && i+1 < len(line) && line[i+1] == '/':
+			return code.String()
+		case c == '/' && i+1 < len(line) && line[i+1] == '*':
+			s.inBlockComment = true
+			i++
+			continue
+		case c == '`':
+			s.inTemplate = true
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '{':
+			s.braces++
+		case c == '}':
+			s.braces--
+		case c == '(':
+			s.parens++
+		case c == ')':
+			s.parens--
+		}
+		code.WriteByte(c)
+	}
+	return code.String()
+}
+
+// Given the content of a TypeScript or JavaScript file, Outline returns its outline.
+func (scriptOutliner) Outline(_ string, content string) (string, error) {
+	o := &scriptOutline{lines: strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")}
+	for o.i < len(o.lines) {
+		o.topLevelStatement()
+	}
+	return o.out.String(), nil
+}
+
+// The state of outlining a single TypeScript or JavaScript file.
+type scriptOutline struct {
+	lines   []string
+	i       int
+	scanner scriptScanner
+	out     strings.Builder
+	// Comment lines that precede the current statement.
+	docs []string
+}
+
+// Returns the next line and its code, advancing past it.
+func (o *scriptOutline) next() (string, string) {
+	line := o.lines[o.i]
+	o.i++
+	return line, strings.TrimSpace(o.scanner.scan(line))
+}
+
+// Processes the statement starting at the current line at the top level of the file.
+func (o *scriptOutline) topLevelStatement() {
+	trimmed := strings.TrimSpace(o.lines[o.i])
+	if trimmed == "" {
+		o.i++
+		return
+	}
+	if o.scanner.inBlockComment || strings.HasPrefix(trimmed, "/*") || strings.HasPrefix(trimmed, "//") {
+		o.collectDoc()
+		return
+	}
+
+	switch {
+	case scriptImportRe.MatchString(trimmed):
+		o.flushDocs(false)
+		o.keepStatement(0)
+	case scriptFuncRe.MatchString(trimmed):
+		o.flushDocs(true)
+		o.keepSignature(0)
+	case scriptClassRe.MatchString(trimmed):
+		o.flushDocs(true)
+		o.keepClass()
+	case scriptTypeDeclRe.MatchString(trimmed), scriptExportRe.MatchString(trimmed):
+		o.flushDocs(true)
+		if strings.HasPrefix(trimmed, "export {") || strings.HasPrefix(trimmed, "export type {") ||
+			scriptTypeDeclRe.MatchString(trimmed) {
+			o.keepStatement(0)
+		} else {
+			o.keepSignature(0)
+		}
+	default:
+		o.docs = nil
+		o.skipStatement(0)
+	}
+}
+
+// Processes the members of a class until its closing brace.
+func (o *scriptOutline) keepClass() {
+	// Keep the class header up to and including the opening brace.
+	for o.i < len(o.lines) {
+		line, code := o.next()
+		o.out.WriteString(line + "\n")
+		if o.scanner.braces > 0 || strings.HasSuffix(code, "}") {
+			break
+		}
+	}
+	blank := false
+	for o.i < len(o.lines) && o.scanner.braces > 0 {
+		trimmed := strings.TrimSpace(o.lines[o.i])
+		if trimmed != "" && blank && len(o.docs) == 0 {
+			o.out.WriteString("\n")
+		}
+		blank = trimmed == ""
+		switch {
+		case trimmed == "":
+			o.i++
+		case o.scanner.inBlockComment || strings.HasPrefix(trimmed, "/*") || strings.HasPrefix(trimmed, "//"):
+			o.collectDoc()
+		case strings.HasPrefix(trimmed, "}") && o.scanner.braces == 1:
+			o.docs = nil
+			line, _ := o.next()
+			o.out.WriteString(line + "\n")
+		default:
+			// Methods and properties are kept, but their bodies and initializers are elided.
+			o.flushDocs(false)
+			o.keepSignature(1)
+		}
+	}
+}
+
+// Keeps the statement starting at the current line up to the opening brace of its
+// body, which is replaced by "{ ... }". Statements without a body are kept as is.
+func (o *scriptOutline) keepSignature(depth int) {
+	for o.i < len(o.lines) {
+		line, code := o.next()
+		if o.scanner.braces > depth && o.scanner.parens <= 0 && strings.HasSuffix(code, "{") {
+			o.out.WriteString(strings.TrimRight(line, " \t{") + " { ... }\n")
+			o.skipBody(depth)
+			return
+		}
+		o.out.WriteString(line + "\n")
+		if o.scanner.braces <= depth && o.scanner.parens <= 0 && statementEnds(code) {
+			return
+		}
+	}
+}
+
+// Keeps every line of the statement starting at the current line.
+func (o *scriptOutline) keepStatement(depth int) {
+	for o.i < len(o.lines) {
+		line, code := o.next()
+		o.out.WriteString(line + "\n")
+		if o.scanner.braces <= depth && o.scanner.parens <= 0 && statementEnds(code) {
+			return
+		}
+	}
+}
+
+// Skips every line of the statement starting at the current line.
+func (o *scriptOutline) skipStatement(depth int) {
+	for o.i < len(o.lines) {
+		_, code := o.next()
+		if o.scanner.braces <= depth && o.scanner.parens <= 0 && statementEnds(code) {
+			return
+		}
+	}
+}
+
+// Skips lines until the body that has been opened closes and the nesting returns to the given depth.
+func (o *scriptOutline) skipBody(depth int) {
+	for o.scanner.braces > depth && o.i < len(o.lines) {
+		o.next()
+	}
+}
+
+// Collects a comment line so that it can be emitted together with the declaration it documents.
+func (o *scriptOutline) collectDoc() {
+	line, _ := o.next()
+	o.docs = append(o.docs, line)
+}
+
+// Writes and clears the collected comment lines, preceded by a blank line if separate is true.
+func (o *scriptOutline) flushDocs(separate bool) {
+	if separate && o.out.Len() > 0 {
+		o.out.WriteString("\n")
+	}
+	for _, doc := range o.docs {
+		o.out.WriteString(doc + "\n")
+	}
+	o.docs = nil
+}
+
+// Returns true if a line of code can end a statement. Lines ending in an operator or
+// an opening bracket continue on the next line.
+func statementEnds(code string) bool {
+	if code == "" {
+		return false
+	}
+	switch code[len(code)-1] {
+	case ',', '=', '|', '&', '(', '[', '{', '<', ':', '?', '+', '-', '.':
+		return false
+	}
+	return true
+}
@@ -0,0 +1,114 @@
+// Package redaction replaces personal and customer data in file contents by
+// pseudonyms, so that bundles can be shared without leaking the data while the
+// code that handles it stays understandable.
+package redaction
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Rule matches one kind of data that is replaced by placeholders named after the rule.
+type Rule struct {
+	Name    string `mapstructure:"name"`
+	Pattern string `mapstructure:"pattern"`
+}
+
+// Built-in rules that can be enabled by name.
+var builtinRules = map[string]string{
+	"email": `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`,
+	"phone": `\+[1-9][0-9 ().-]{7,18}[0-9]|\(?\b[0-9]{3}\)?[ .-][0-9]{3}[ .-][0-9]{4}\b`,
+	"ipv4":  `\b(25[0-5]|2[0-4][0-9]|1?[0-9]?[0-9])(\.(25[0-5]|2[0-4][0-9]|1?[0-9]?[0-9])){3}\b`,
+	"ipv6":  `\b([0-9A-Fa-f]{1,4}:){7}[0-9A-Fa-f]{1,4}\b|\b([0-9A-Fa-f]{1,4}:){1,6}(:[0-9A-Fa-f]{1,4}){1,6}\b`,
+}
+
+// BuiltinRuleNames returns the names of the built-in rules in alphabetical order.
+func BuiltinRuleNames() []string {
+	names := make([]string, 0, len(builtinRules))
+	for name := range builtinRules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// A rule with its compiled pattern.
+type compiledRule struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// Redactor replaces the matches of its rules by placeholders such as "<EMAIL_1>".
+// The same value is always replaced by the same placeholder.
+type Redactor struct {
+	rules []compiledRule
+	// Placeholders that have been assigned, per rule and value.
+	pseudonyms map[string]map[string]string
+}
+
+// NewRedactor returns a redactor for the given built-in rule names and custom rules.
+// Custom rules are applied first, so they can override built-in ones.
+func NewRedactor(builtins []string, custom []Rule) (*Redactor, error) {
+	r := &Redactor{pseudonyms: make(map[string]map[string]string)}
+	for _, rule := range custom {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("redaction rule with pattern %q has no name", rule.Pattern)
+		}
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern for redaction rule %q: %w", rule.Name, err)
+		}
+		r.rules = append(r.rules, compiledRule{name: rule.Name, pattern: pattern})
+	}
+	for _, name := range builtins {
+		pattern, ok := builtinRules[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown redaction rule %q, available rules: %s",
+				name, strings.Join(BuiltinRuleNames(), ", "))
+		}
+		r.rules = append(r.rules, compiledRule{name: strings.ToLower(name), pattern: regexp.MustCompile(pattern)})
+	}
+	return r, nil
+}
+
+// Redact returns a copy of the given map of file paths to their content in which all
+// matches are replaced by placeholders, and the number of replaced matches per rule.
+// Files are processed in lexicographic order so placeholders are deterministic.
+func (r *Redactor) Redact(fileContentMap map[string]string) (map[string]string, map[string]int) {
+	paths := make([]string, 0, len(fileContentMap))
+	for path := range fileContentMap {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	counts := make(map[string]int)
+	redacted := make(map[string]string, len(fileContentMap))
+	for _, path := range paths {
+		content := fileContentMap[path]
+		for _, rule := range r.rules {
+			content = rule.pattern.ReplaceAllStringFunc(content, func(value string) string {
+				counts[rule.name]++
+				return r.pseudonym(rule.name, value)
+			})
+		}
+		redacted[path] = content
+	}
+	return redacted, counts
+}
+
+// Returns the placeholder for a value matched by a rule, assigning a new one if needed.
+func (r *Redactor) pseudonym(rule string, value string) string {
+	values, ok := r.pseudonyms[rule]
+	if !ok {
+		values = make(map[string]string)
+		r.pseudonyms[rule] = values
+	}
+	if placeholder, ok := values[value]; ok {
+		return placeholder
+	}
+	placeholder := fmt.Sprintf("<%s_%d>", strings.ToUpper(rule), len(values)+1)
+	values[value] = placeholder
+	return placeholder
+}
@@ -0,0 +1,83 @@
+// Contains code to cache review results, so unchanged code is not sent for review again.
+package review
+
+import (
+	"errors"
+	"log"
+
+	"github.com/vossenwout/crev/internal/cache"
+	reviewapi "github.com/vossenwout/crev/pkg/review"
+)
+
+// A review result as it is stored in the cache.
+type cachedReview struct {
+	Review          string                     `json:"review"`
+	Findings        []reviewapi.Finding        `json:"findings"`
+	InvalidFindings []reviewapi.InvalidFinding `json:"invalid_findings"`
+	FindingsError   string                     `json:"findings_error,omitempty"`
+	FindingsMissing bool                       `json:"findings_missing,omitempty"`
+	Provider        string                     `json:"provider"`
+	Chunks          int                        `json:"chunks"`
+}
+
+// CacheKey returns the key of the review of the code by the provider. The destination of
+// the provider includes its model, and other instructions lead to another review.
+func CacheKey(codeToReview string, provider reviewapi.Provider, instructions string) string {
+	return cache.Key(codeToReview, provider.Name(), provider.Destination(), instructions)
+}
+
+// Cached reports whether the review of the code by the provider is in the cache, so it
+// will not be sent. A nil cache has no reviews.
+func Cached(c *cache.Cache, codeToReview string, provider reviewapi.Provider, instructions string) bool {
+	if c == nil {
+		return false
+	}
+	var entry cachedReview
+	_, ok, err := c.Get(CacheKey(codeToReview, provider, instructions), &entry)
+	return err == nil && ok
+}
+
+// Returns the cached review with the key, or nil if there is none.
+func cachedResult(c *cache.Cache, key string) *reviewapi.Result {
+	var entry cachedReview
+	stored, ok, err := c.Get(key, &entry)
+	if err != nil {
+		log.Printf("Ignoring the review cache: %v", err)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+	log.Printf("Using the review of this code cached on %s, nothing was sent. Use --no-cache to review it again.",
+		stored.Format("2006-01-02 15:04"))
+	result := &reviewapi.Result{
+		Review:          entry.Review,
+		Findings:        entry.Findings,
+		InvalidFindings: entry.InvalidFindings,
+		FindingsMissing: entry.FindingsMissing,
+		Provider:        entry.Provider,
+		Chunks:          entry.Chunks,
+	}
+	if entry.FindingsError != "" {
+		result.FindingsError = errors.New(entry.FindingsError)
+	}
+	return result
+}
+
+// Stores the result of a review in the cache. Failing to do so does not fail the review.
+func cacheResult(c *cache.Cache, key string, result *reviewapi.Result) {
+	entry := cachedReview{
+		Review:          result.Review,
+		Findings:        result.Findings,
+		InvalidFindings: result.InvalidFindings,
+		FindingsMissing: result.FindingsMissing,
+		Provider:        result.Provider,
+		Chunks:          result.Chunks,
+	}
+	if result.FindingsError != nil {
+		entry.FindingsError = result.FindingsError.Error()
+	}
+	if err := c.Put(key, entry); err != nil {
+		log.Printf("Error caching the review: %v", err)
+	}
+}
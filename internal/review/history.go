@@ -0,0 +1,102 @@
+// Contains code to keep reviews in the history and to show and compare the runs in it.
+package review
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vossenwout/crev/internal/gitdiff"
+	"github.com/vossenwout/crev/internal/history"
+	reviewapi "github.com/vossenwout/crev/pkg/review"
+)
+
+// Adds the review to the history. Failing to do so does not fail the review.
+func saveRun(h *history.History, codeToReview string, provider reviewapi.Provider, review string, findings []reviewapi.Finding, opts Options) {
+	run := &history.Run{
+		Time:        time.Now(),
+		Commit:      gitdiff.Commit(context.Background(), "."),
+		Provider:    provider.Name(),
+		Model:       opts.Model,
+		Destination: provider.Destination(),
+		BundleHash:  history.BundleHash(codeToReview),
+		Review:      review,
+		Findings:    findings,
+	}
+	if run.Findings == nil {
+		run.Findings = []reviewapi.Finding{}
+	}
+	if err := h.Save(run); err != nil {
+		log.Printf("Error adding the review to the history: %v", err)
+		return
+	}
+	log.Printf("Added the review to the history as %s", run.ID)
+}
+
+// Returns the first characters of a hash, as shown in tables.
+func short(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
+// Returns the model of a run, or "default" for the default model of the provider.
+func model(run *history.Run) string {
+	if run.Model == "" {
+		return "default"
+	}
+	return run.Model
+}
+
+// FormatHistory returns a table of the runs, one line per run.
+func FormatHistory(runs []*history.Run) string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTIME\tCOMMIT\tPROVIDER\tMODEL\tBUNDLE\tFINDINGS")
+	for _, run := range runs {
+		commit := short(run.Commit)
+		if commit == "" {
+			commit = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\n", run.ID, run.Time.Format("2006-01-02 15:04"), commit,
+			run.Provider, model(run), short(run.BundleHash), len(run.Findings))
+	}
+	w.Flush()
+	return sb.String()
+}
+
+// Returns a line that describes a run.
+func describeRun(run *history.Run) string {
+	description := fmt.Sprintf("%s (%s, %s %s", run.ID, run.Time.Format("2006-01-02 15:04"), run.Provider, model(run))
+	if run.Commit != "" {
+		description += ", commit " + short(run.Commit)
+	}
+	return description + ")"
+}
+
+// FormatComparison returns the findings that were fixed, persisted and introduced between
+// the runs a and b.
+func FormatComparison(a *history.Run, b *history.Run, comparison history.Comparison) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Comparing %s\n     with %s\n", describeRun(a), describeRun(b))
+	if a.BundleHash == b.BundleHash {
+		sb.WriteString("Both runs reviewed the same code.\n")
+	}
+	sections := []struct {
+		title    string
+		findings []reviewapi.Finding
+	}{
+		{"Fixed", comparison.Fixed},
+		{"Persisted", comparison.Persisted},
+		{"Introduced", comparison.Introduced},
+	}
+	for _, section := range sections {
+		fmt.Fprintf(&sb, "\n%s (%d):\n", section.title, len(section.findings))
+		sb.WriteString(Summary(section.findings))
+	}
+	return sb.String()
+}
@@ -0,0 +1,43 @@
+// Contains code to collect the instructions of a team that are sent with review requests.
+package review
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	reviewapi "github.com/vossenwout/crev/pkg/review"
+)
+
+// RulesFile is the file with the review rules of a team, read from the current directory.
+const RulesFile = ".crev-rules.md"
+
+// Instructions combines the rules in the rules file, if it exists, the instructions from
+// the config and the instructions of the focus presets. It also returns where the
+// instructions come from, to show the user what is sent.
+func Instructions(rulesFile string, configured string, focus []string) (string, []string, error) {
+	var parts, sources []string
+	dat, err := os.ReadFile(rulesFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", nil, fmt.Errorf("error reading %s: %w", rulesFile, err)
+	}
+	if rules := strings.TrimSpace(string(dat)); rules != "" {
+		parts = append(parts, rules)
+		sources = append(sources, rulesFile)
+	}
+	if configured = strings.TrimSpace(configured); configured != "" {
+		parts = append(parts, configured)
+		sources = append(sources, "review.instructions in the config")
+	}
+	for _, name := range focus {
+		preset, err := reviewapi.Focus(name)
+		if err != nil {
+			return "", nil, err
+		}
+		parts = append(parts, preset)
+		sources = append(sources, "focus "+strings.ToLower(name))
+	}
+	return strings.Join(parts, "\n\n"), sources, nil
+}
@@ -0,0 +1,112 @@
+// Contains code to summarize what is sent to the review service and to ask the user for confirmation.
+package review
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/vossenwout/crev/internal/formatting"
+	"github.com/vossenwout/crev/internal/tokens"
+)
+
+// Base name patterns of files that commonly contain credentials or other sensitive data.
+var sensitivePatterns = []string{
+	".env", ".env.*", "*.env",
+	"*.pem", "*.key", "*.p12", "*.pfx", "*.jks", "*.keystore", "*.kdbx",
+	"id_rsa*", "id_dsa*", "id_ecdsa*", "id_ed25519*",
+	"credentials*", "secrets*", "*secret*.json", "*secret*.yaml", "*secret*.yml",
+	".npmrc", ".pypirc", ".netrc", ".htpasswd",
+	"*.tfstate", "*.tfstate.*", "*.tfvars",
+}
+
+// Manifest summarizes the content that is about to be sent for review.
+type Manifest struct {
+	Files          int
+	Size           int
+	MinTokens      int
+	MaxTokens      int
+	Destination    string
+	SensitiveFiles []string
+}
+
+// Given the code to review and where it is sent, NewManifest summarizes what is sent.
+func NewManifest(codeToReview string, destination string) Manifest {
+	fileContentMap := formatting.ParseProjectString(codeToReview)
+	minTokens, maxTokens := tokens.Range(codeToReview)
+	manifest := Manifest{
+		Files:       len(fileContentMap),
+		Size:        len(codeToReview),
+		MinTokens:   minTokens,
+		MaxTokens:   maxTokens,
+		Destination: destination,
+	}
+	for path := range fileContentMap {
+		if IsSensitiveFile(path) {
+			manifest.SensitiveFiles = append(manifest.SensitiveFiles, path)
+		}
+	}
+	sort.Strings(manifest.SensitiveFiles)
+	return manifest
+}
+
+// IsSensitiveFile returns true if the name of the file suggests it contains credentials.
+func IsSensitiveFile(path string) bool {
+	base := strings.ToLower(filepath.Base(path))
+	for _, pattern := range sensitivePatterns {
+		if matched, _ := filepath.Match(pattern, base); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// String renders the manifest as shown to the user before uploading.
+func (m Manifest) String() string {
+	var sb strings.Builder
+	sb.WriteString("The following will be sent for review:\n")
+	fmt.Fprintf(&sb, "  %-18s %d\n", "Files:", m.Files)
+	fmt.Fprintf(&sb, "  %-18s %s\n", "Total size:", formatSize(m.Size))
+	fmt.Fprintf(&sb, "  %-18s %d - %d\n", "Estimated tokens:", m.MinTokens, m.MaxTokens)
+	fmt.Fprintf(&sb, "  %-18s %s\n", "Destination:", m.Destination)
+	if len(m.SensitiveFiles) > 0 {
+		sb.WriteString("  Warning, files that may contain sensitive data:\n")
+		for _, path := range m.SensitiveFiles {
+			sb.WriteString("    - " + path + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// Returns a human readable representation of a number of bytes.
+func formatSize(size int) string {
+	switch {
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(size)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
+
+// IsInteractive returns true if stdin is attached to a terminal, so the user can answer questions.
+func IsInteractive() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Confirm writes the question to out and returns true if the answer read from in is yes.
+func Confirm(question string, in io.Reader, out io.Writer) bool {
+	fmt.Fprint(out, question+" [y/N]: ")
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && answer == "" {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
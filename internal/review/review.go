@@ -1,100 +1,389 @@
+// Package review runs reviews from the command line on top of the public review package:
+// it asks for confirmation, renders the review and saves it to a file.
 package review
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
-	"io"
+	"errors"
+	"fmt"
 	"log"
-	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
 
+	"github.com/vossenwout/crev/internal/baseline"
+	"github.com/vossenwout/crev/internal/cache"
 	"github.com/vossenwout/crev/internal/files"
+	"github.com/vossenwout/crev/internal/formatting"
+	"github.com/vossenwout/crev/internal/history"
+	"github.com/vossenwout/crev/internal/sarif"
+	"github.com/vossenwout/crev/internal/suppress"
+	reviewapi "github.com/vossenwout/crev/pkg/review"
 )
 
-type ReviewInput struct {
-	Code string `json:"code"`
-}
+// Exit codes of the review command, so that CI can react to the kind of failure.
+const (
+	ExitFailure   = 1
+	ExitFindings  = 2
+	ExitAuth      = 3
+	ExitQuota     = 4
+	ExitTransient = 5
+)
 
-type ReviewOutput struct {
-	Review string `json:"review"`
+// Options configures a review started from the command line.
+type Options struct {
+	// SkipConfirmation sends the code without asking the user first.
+	SkipConfirmation bool
+	// Stream renders the review to the terminal as it arrives, if the provider supports it.
+	Stream bool
+	// LineCounts overrides the line counts of the reviewed files used to validate findings.
+	LineCounts map[string]int
+	// Format is the format of the saved review: "markdown" (default) or "sarif".
+	Format string
+	// SARIF configures the conversion of findings when the format is sarif.
+	SARIF sarif.Options
+	// FailOn exits with ExitFindings when a finding is at least this serious.
+	// An empty severity never fails.
+	FailOn reviewapi.Severity
+	// MaxFindings keeps only the most serious findings if there are more. Zero keeps all.
+	MaxFindings int
+	// Files overrides the content of the reviewed files used to fingerprint findings.
+	// It defaults to the file sections of the code to review.
+	Files map[string]string
+	// Baseline is the file with accepted findings that are not reported. Empty reports all.
+	Baseline string
+	// WriteBaseline accepts all findings of the review by writing them to the baseline.
+	WriteBaseline bool
+	// ChunkTokens reviews code of more estimated tokens in chunks. Zero never chunks.
+	ChunkTokens int
+	// Concurrency is the number of chunks that are reviewed at the same time.
+	Concurrency int
+	// Cache returns the earlier result when the same code was reviewed before with the same
+	// provider and instructions, and stores new results. Nil disables caching.
+	Cache *cache.Cache
+	// Instructions are the instructions the provider was created with, part of the cache key.
+	Instructions string
+	// History keeps the review with the metadata of the run. Nil does not keep it.
+	History *history.History
+	// Model is the configured model, recorded in the history.
+	Model string
 }
 
-const reviewURL = "https://reviewcode-qcgl4feadq-uc.a.run.app"
+// Formats in which a review can be saved.
+var Formats = []string{"markdown", "sarif"}
 
-func prepareRequest(codeToReview string, apiKey string) (*http.Request, error) {
-	input := ReviewInput{
-		Code: codeToReview,
+// ExitCode returns the exit code for an error returned by a review.
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, reviewapi.ErrUnauthorized):
+		return ExitAuth
+	case errors.Is(err, reviewapi.ErrQuota):
+		return ExitQuota
+	case reviewapi.IsTemporary(err):
+		return ExitTransient
 	}
-	jsonData, err := json.Marshal(input)
+	return ExitFailure
+}
+
+// Files the findings of a review are saved to, next to crev-review.md.
+const (
+	FindingsFile = "crev-findings.json"
+	SARIFFile    = "crev-review.sarif"
+)
+
+// FindingsReport is the content of the findings file.
+type FindingsReport struct {
+	Findings []reviewapi.Finding `json:"findings"`
+	// Suppressed lists the findings suppressed by crev:ignore comments and their reasons.
+	Suppressed []suppress.Suppressed `json:"suppressed"`
+}
+
+func saveReviewToFile(review string) error {
+	err := files.SaveStringToFile(review, "crev-review.md")
 	if err != nil {
-		return nil, err
+		return err
 	}
-	req, err := http.NewRequest("POST", reviewURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, err
+	log.Printf("Successfully saved code review to crev-review.md")
+	return nil
+}
+
+// Summary returns one line per finding, as shown in CI logs.
+func Summary(findings []reviewapi.Finding) string {
+	var sb strings.Builder
+	for _, finding := range findings {
+		sb.WriteString(finding.String() + "\n")
 	}
-	// Set the request header to specify JSON format
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("api-key", apiKey)
+	return sb.String()
+}
 
-	return req, nil
+// FailingFindings returns the number of findings that are at least as serious as the threshold.
+func FailingFindings(findings []reviewapi.Finding, threshold reviewapi.Severity) int {
+	if threshold == "" {
+		return 0
+	}
+	count := 0
+	for _, finding := range findings {
+		if finding.Severity.AtLeast(threshold) {
+			count++
+		}
+	}
+	return count
 }
 
-func sendRequest(req *http.Request) (*http.Response, error) {
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Fatalf("Error sending review request to %s: %v", reviewURL, err)
+// UnknownFindings returns why the findings of the review are unknown when findings at least
+// as serious as the threshold fail the review, or nil if they are known or nothing fails.
+// A review that fails on findings cannot pass if its findings could not be checked.
+func UnknownFindings(result *reviewapi.Result, threshold reviewapi.Severity) error {
+	if threshold == "" {
+		return nil
+	}
+	if result.FindingsError != nil {
+		return fmt.Errorf("the findings of the review could not be parsed: %w", result.FindingsError)
+	}
+	if result.FindingsMissing {
+		return fmt.Errorf("the review of %s does not list its findings", result.Provider)
 	}
+	return nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		log.Printf("Error: received status code %d: %s", resp.StatusCode, string(body))
-		if resp.StatusCode == http.StatusUnauthorized {
-			log.Fatalf("Unauthorized: you have provided an invalid CREV API key.")
-		} else {
-			log.Fatalf("Failed to review code: status code %d", resp.StatusCode)
-		}
-		return nil, err
+// Fingerprints the findings of the review and returns the content of the reviewed files
+// the fingerprints are based on.
+func fingerprintFindings(result *reviewapi.Result, codeToReview string, opts Options) map[string]string {
+	contents := opts.Files
+	if contents == nil {
+		contents = formatting.ParseProjectString(codeToReview)
 	}
-	return resp, nil
+	for i, finding := range result.Findings {
+		result.Findings[i].Fingerprint = baseline.Fingerprint(finding, contents[finding.Path])
+	}
+	return contents
 }
 
-func saveReviewToFile(output ReviewOutput) error {
-	err := files.SaveStringToFile(output.Review, "crev-review.md")
+// Removes the findings of the review that are suppressed by crev:ignore comments in the
+// contents or accepted in the baseline. Returns the suppressed findings.
+func processFindings(result *reviewapi.Result, contents map[string]string, opts Options) ([]suppress.Suppressed, error) {
+	var suppressed []suppress.Suppressed
+	var errs []error
+	result.Findings, suppressed, errs = suppress.Apply(result.Findings, contents)
+	for _, err := range errs {
+		log.Printf("Ignoring suppression comment: %v", err)
+	}
+	if len(suppressed) > 0 {
+		log.Printf("Suppressed %d findings with crev:ignore comments", len(suppressed))
+	}
+
+	return suppressed, applyBaseline(result, opts)
+}
+
+// If a baseline is used, writes the findings to it or removes the findings it accepts.
+func applyBaseline(result *reviewapi.Result, opts Options) error {
+	if opts.Baseline == "" {
+		return nil
+	}
+	if opts.WriteBaseline {
+		err := baseline.New(result.Findings).Save(opts.Baseline)
+		if err != nil {
+			return err
+		}
+		log.Printf("Accepted %d findings in baseline %s", len(result.Findings), opts.Baseline)
+	}
+	accepted, err := baseline.Load(opts.Baseline)
 	if err != nil {
 		return err
 	}
-	log.Printf("Successfully saved code review to crev-review.md")
+	var suppressed int
+	result.Findings, suppressed = accepted.Filter(result.Findings)
+	if suppressed > 0 {
+		log.Printf("Suppressed %d findings that are accepted in baseline %s", suppressed, opts.Baseline)
+	}
 	return nil
 }
 
-func Review(codeToReview string, apiKey string) {
-	log.Printf("Reviewing code please wait...")
+// SuppressionsMarkdown returns a section that lists the suppressed findings and the reasons
+// they were suppressed, which is added to the review so suppressions can be audited.
+func SuppressionsMarkdown(suppressed []suppress.Suppressed) string {
+	if len(suppressed) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n## Suppressed findings\n\n")
+	for _, s := range suppressed {
+		fmt.Fprintf(&sb, "- %s\n  - Suppressed by `crev:ignore` at `%s`: %s\n", s.Finding, s.Comment, s.Reason)
+	}
+	return sb.String()
+}
 
-	// Prepare the request to review the code
-	req, err := prepareRequest(codeToReview, apiKey)
+// Saves the findings of the review as a SARIF log.
+func saveSARIFToFile(findings []reviewapi.Finding, suppressed []suppress.Suppressed, opts sarif.Options) error {
+	sarifSuppressed := make([]sarif.Suppressed, 0, len(suppressed))
+	for _, s := range suppressed {
+		sarifSuppressed = append(sarifSuppressed, sarif.Suppressed{
+			Finding:       s.Finding,
+			Justification: s.Reason + " (crev:ignore at " + s.Comment + ")",
+		})
+	}
+	data, err := sarif.FromFindings(findings, sarifSuppressed, opts).Marshal()
+	if err != nil {
+		return err
+	}
+	err = files.SaveStringToFile(string(data)+"\n", SARIFFile)
 	if err != nil {
-		log.Fatalf("Error preparing review request: %v", err)
+		return err
 	}
+	log.Printf("Successfully saved code review to %s", SARIFFile)
+	return nil
+}
 
-	// Send the request to review the code
-	resp, err := sendRequest(req)
+// Saves the valid findings of the review and reports the invalid ones.
+func saveFindingsToFile(result *reviewapi.Result, suppressed []suppress.Suppressed) error {
+	if result.FindingsError != nil {
+		log.Printf("The review has no structured findings: %v", result.FindingsError)
+	}
+	for _, invalid := range result.InvalidFindings {
+		log.Printf("Ignoring finding in %s: %s", invalid.Finding.Path, invalid.Reason)
+	}
+	report := FindingsReport{Findings: result.Findings, Suppressed: suppressed}
+	if report.Findings == nil {
+		report.Findings = []reviewapi.Finding{}
+	}
+	if report.Suppressed == nil {
+		report.Suppressed = []suppress.Suppressed{}
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
 	if err != nil {
-		log.Fatalf("Error sending review request: %v", err)
+		return err
+	}
+	err = files.SaveStringToFile(string(data)+"\n", FindingsFile)
+	if err != nil {
+		return err
+	}
+	log.Printf("Saved %d findings to %s", len(report.Findings), FindingsFile)
+	return nil
+}
+
+// Review shows a summary of the code to review and, unless confirmation is skipped,
+// asks the user for confirmation before sending it to the provider. Code that has been
+// reviewed before is not sent again if the result is cached.
+func Review(codeToReview string, provider reviewapi.Provider, opts Options) {
+	var result *reviewapi.Result
+	var key string
+	if opts.Cache != nil {
+		key = CacheKey(codeToReview, provider, opts.Instructions)
+		result = cachedResult(opts.Cache, key)
+		if result != nil && opts.Stream {
+			fmt.Println(result.Review)
+		}
+	}
+	if result == nil {
+		result = requestReview(codeToReview, provider, opts)
+		if opts.Cache != nil {
+			cacheResult(opts.Cache, key, result)
+		}
 	}
-	defer resp.Body.Close()
 
-	// Decode the response
-	var output ReviewOutput
-	err = json.NewDecoder(resp.Body).Decode(&output)
+	contents := fingerprintFindings(result, codeToReview, opts)
+	// The history keeps the findings before suppressions and the baseline apply, so that
+	// accepting a finding does not look like fixing it when runs are compared.
+	if opts.History != nil {
+		findings := slices.Clone(result.Findings)
+		reviewapi.SortFindings(findings)
+		saveRun(opts.History, codeToReview, provider, result.Review, findings, opts)
+	}
+	suppressed, err := processFindings(result, contents, opts)
 	if err != nil {
-		log.Fatalf("Error decoding review response: %v", err)
+		log.Fatalf("Error processing findings: %v", err)
+	}
+
+	// The threshold applies to all findings, also those beyond the maximum.
+	reviewapi.SortFindings(result.Findings)
+	failing := FailingFindings(result.Findings, opts.FailOn)
+	if opts.MaxFindings > 0 && len(result.Findings) > opts.MaxFindings {
+		log.Printf("Keeping the %d most serious of %d findings", opts.MaxFindings, len(result.Findings))
+		result.Findings = result.Findings[:opts.MaxFindings]
 	}
 
-	// Save the review to a file
-	err = saveReviewToFile(output)
+	// Save the review and its findings to files
+	err = saveFindingsToFile(result, suppressed)
+	if err != nil {
+		log.Fatalf("Error saving findings to file: %v", err)
+	}
+	if opts.Format == "sarif" {
+		err = saveSARIFToFile(result.Findings, suppressed, opts.SARIF)
+	} else {
+		err = saveReviewToFile(result.Review + SuppressionsMarkdown(suppressed))
+	}
 	if err != nil {
 		log.Fatalf("Error saving review to file: %v", err)
 	}
 
+	if len(result.Findings) > 0 {
+		fmt.Print("\nFindings:\n" + Summary(result.Findings))
+	}
+	if len(suppressed) > 0 {
+		fmt.Println("\nSuppressed findings:")
+		for _, s := range suppressed {
+			fmt.Printf("%s (crev:ignore at %s: %s)\n", s.Finding, s.Comment, s.Reason)
+		}
+	}
+	if err := UnknownFindings(result, opts.FailOn); err != nil {
+		log.Printf("Failing because %v", err)
+		os.Exit(ExitFailure)
+	}
+	if failing > 0 {
+		log.Printf("Failing because %d findings have severity %s or higher", failing, opts.FailOn)
+		os.Exit(ExitFindings)
+	}
+}
+
+// Sends the code for review after confirmation and returns the result. Exits if the
+// review fails, after saving the part of a streamed review that has been received.
+func requestReview(codeToReview string, provider reviewapi.Provider, opts Options) *reviewapi.Result {
+	fmt.Print(NewManifest(codeToReview, provider.Destination()))
+	if !opts.SkipConfirmation {
+		if !IsInteractive() {
+			log.Fatal("Refusing to send code for review without confirmation. Run with --yes to confirm non-interactively.")
+		}
+		if !Confirm("Send this code for review?", os.Stdin, os.Stdout) {
+			log.Fatal("Review cancelled, nothing was sent.")
+		}
+	}
+
+	// Stop waiting for the review when the user presses ctrl+c, keeping what has been received.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	apiOpts := reviewapi.Options{
+		LineCounts:  opts.LineCounts,
+		ChunkTokens: opts.ChunkTokens,
+		Concurrency: opts.Concurrency,
+		OnChunk: func(done int, total int) {
+			log.Printf("Reviewed part %d of %d", done, total)
+		},
+	}
+	if _, ok := provider.(reviewapi.StreamingProvider); ok && opts.Stream {
+		log.Printf("Reviewing code, the review is shown while it is written...")
+		apiOpts.Stream = os.Stdout
+	} else {
+		log.Printf("Reviewing code please wait...")
+	}
+	result, err := reviewapi.ReviewWith(ctx, provider, strings.NewReader(codeToReview), apiOpts)
+	if apiOpts.Stream != nil {
+		fmt.Println()
+	}
+	if err != nil {
+		log.Printf("Failed to review code: %v", err)
+		// Keep the part of a streamed review that has been received.
+		if result != nil {
+			partial := result.Review + fmt.Sprintf("\n\n---\n\n*This review is incomplete, it was interrupted: %v*\n", err)
+			if saveErr := saveReviewToFile(partial); saveErr != nil {
+				log.Printf("Error saving partial review to file: %v", saveErr)
+			}
+		}
+		os.Exit(ExitCode(err))
+	}
+	return result
 }
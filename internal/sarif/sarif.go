@@ -0,0 +1,260 @@
+// Package sarif converts review findings into a SARIF 2.1.0 log, the format code scanning
+// dashboards use to show the results of linters and other analysis tools.
+package sarif
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/vossenwout/crev/pkg/review"
+)
+
+// Version and schema of the SARIF logs that are written.
+const (
+	Version = "2.1.0"
+	Schema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// Names of the fingerprints of results, so dashboards can track findings across runs. Every
+// result has a fingerprint of its message, and one of its code if the finding has one.
+const (
+	fingerprintName     = "crevFinding/v1"
+	codeFingerprintName = "crevCode/v1"
+)
+
+// Log is a SARIF log with a single run.
+type Log struct {
+	Schema  string `json:"$schema"`
+	Version string `json:"version"`
+	Runs    []Run  `json:"runs"`
+}
+
+// Run is the output of a single review.
+type Run struct {
+	Tool       Tool     `json:"tool"`
+	Results    []Result `json:"results"`
+	ColumnKind string   `json:"columnKind,omitempty"`
+}
+
+// Tool describes crev and the rules its findings belong to.
+type Tool struct {
+	Driver Driver `json:"driver"`
+}
+
+// Driver is the component of the tool that produced the results.
+type Driver struct {
+	Name           string `json:"name"`
+	Version        string `json:"version,omitempty"`
+	InformationURI string `json:"informationUri"`
+	Rules          []Rule `json:"rules"`
+}
+
+// Rule is a category of findings.
+type Rule struct {
+	ID                   string            `json:"id"`
+	Name                 string            `json:"name"`
+	ShortDescription     Message           `json:"shortDescription"`
+	DefaultConfiguration RuleConfiguration `json:"defaultConfiguration"`
+}
+
+// RuleConfiguration is the default level of the results of a rule.
+type RuleConfiguration struct {
+	Level string `json:"level"`
+}
+
+// Message is a text shown to the user.
+type Message struct {
+	Text string `json:"text"`
+}
+
+// Result is a single finding.
+type Result struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             Message           `json:"message"`
+	Locations           []Location        `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+	Suppressions        []Suppression     `json:"suppressions,omitempty"`
+	Properties          map[string]string `json:"properties,omitempty"`
+}
+
+// Suppression explains why a result is not reported.
+type Suppression struct {
+	Kind          string `json:"kind"`
+	Justification string `json:"justification,omitempty"`
+}
+
+// Suppressed is a finding that was suppressed in the source code.
+type Suppressed struct {
+	Finding       review.Finding
+	Justification string
+}
+
+// Location is where a finding was raised.
+type Location struct {
+	PhysicalLocation PhysicalLocation `json:"physicalLocation"`
+}
+
+// PhysicalLocation is a file and the lines in it.
+type PhysicalLocation struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+	Region           *Region          `json:"region,omitempty"`
+}
+
+// ArtifactLocation is the path of a file relative to a base.
+type ArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+// Region is a range of lines.
+type Region struct {
+	StartLine int `json:"startLine"`
+	EndLine   int `json:"endLine,omitempty"`
+}
+
+// Options configures the conversion of findings.
+type Options struct {
+	// ToolVersion is the version of crev that wrote the review.
+	ToolVersion string
+	// PathPrefix is prepended to the paths of findings to make them relative to the
+	// root of the repository, ex. "services/api/" when crev ran in that directory.
+	PathPrefix string
+}
+
+// FromFindings returns a SARIF log with a result for every finding and a rule for every
+// category of findings. Suppressed findings are included as results with an in source
+// suppression, so dashboards can show them as dismissed.
+func FromFindings(findings []review.Finding, suppressed []Suppressed, opts Options) *Log {
+	driver := Driver{
+		Name:           "crev",
+		Version:        opts.ToolVersion,
+		InformationURI: "https://crevcli.com",
+		Rules:          []Rule{},
+	}
+	all := slices.Clone(findings)
+	for _, s := range suppressed {
+		all = append(all, s.Finding)
+	}
+	ruleIndex := make(map[string]int)
+	for _, category := range categories(all) {
+		ruleIndex[category] = len(driver.Rules)
+		driver.Rules = append(driver.Rules, Rule{
+			ID:                   ruleID(category),
+			Name:                 ruleName(category),
+			ShortDescription:     Message{Text: "crev review finding: " + category},
+			DefaultConfiguration: RuleConfiguration{Level: "warning"},
+		})
+	}
+
+	results := []Result{}
+	for i, finding := range all {
+		uri := path.Clean(opts.PathPrefix + strings.ReplaceAll(finding.Path, "\\", "/"))
+		location := PhysicalLocation{ArtifactLocation: ArtifactLocation{URI: uri, URIBaseID: "%SRCROOT%"}}
+		if finding.StartLine > 0 {
+			location.Region = &Region{StartLine: finding.StartLine, EndLine: finding.EndLine}
+		}
+		text := finding.Message
+		if finding.Suggestion != "" {
+			text += "\n\nSuggestion: " + finding.Suggestion
+		}
+		results = append(results, Result{
+			RuleID:              ruleID(category(finding)),
+			RuleIndex:           ruleIndex[category(finding)],
+			Level:               Level(finding.Severity),
+			Message:             Message{Text: text},
+			Locations:           []Location{{PhysicalLocation: location}},
+			PartialFingerprints: fingerprints(uri, finding),
+			Properties:          map[string]string{"severity": string(finding.Severity)},
+		})
+		if i >= len(findings) {
+			results[i].Suppressions = []Suppression{{Kind: "inSource", Justification: suppressed[i-len(findings)].Justification}}
+		}
+	}
+
+	return &Log{
+		Schema:  Schema,
+		Version: Version,
+		Runs: []Run{{
+			Tool:       Tool{Driver: driver},
+			Results:    results,
+			ColumnKind: "unicodeCodePoints",
+		}},
+	}
+}
+
+// Marshal returns the log as indented JSON.
+func (l *Log) Marshal() ([]byte, error) {
+	return json.MarshalIndent(l, "", "  ")
+}
+
+// Level returns the SARIF level of a severity.
+func Level(severity review.Severity) string {
+	switch severity {
+	case review.SeverityCritical, review.SeverityHigh:
+		return "error"
+	case review.SeverityMedium:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// Fingerprint identifies a finding independently of its line numbers, so the same issue
+// is recognized when code above it moves.
+func Fingerprint(uri string, finding review.Finding) string {
+	hash := sha256.Sum256([]byte(uri + "\x00" + category(finding) + "\x00" + strings.Join(strings.Fields(strings.ToLower(finding.Message)), " ")))
+	return hex.EncodeToString(hash[:16])
+}
+
+// Returns the fingerprints of the result of a finding.
+func fingerprints(uri string, finding review.Finding) map[string]string {
+	result := map[string]string{fingerprintName: Fingerprint(uri, finding)}
+	if finding.Fingerprint != "" {
+		result[codeFingerprintName] = finding.Fingerprint
+	}
+	return result
+}
+
+// Returns the category of a finding, "general" if the reviewer did not give one.
+func category(finding review.Finding) string {
+	c := strings.ToLower(strings.TrimSpace(finding.Category))
+	if c == "" {
+		return "general"
+	}
+	return c
+}
+
+// Returns the distinct categories of the findings in alphabetical order.
+func categories(findings []review.Finding) []string {
+	seen := make(map[string]bool)
+	var result []string
+	for _, finding := range findings {
+		if c := category(finding); !seen[c] {
+			seen[c] = true
+			result = append(result, c)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Returns the id of the rule for a category.
+func ruleID(category string) string {
+	return "crev/" + strings.ReplaceAll(category, " ", "-")
+}
+
+// Returns the name of the rule for a category in PascalCase, as SARIF recommends.
+func ruleName(category string) string {
+	var sb strings.Builder
+	for _, word := range strings.FieldsFunc(category, func(r rune) bool { return r == ' ' || r == '-' || r == '_' }) {
+		sb.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return sb.String()
+}
@@ -0,0 +1,143 @@
+// Package secrets detects credentials in file contents and redacts them before they
+// are written to a bundle or sent to a review service.
+package secrets
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Finding describes a secret that has been redacted.
+type Finding struct {
+	Path string
+	Line int
+	Kind string
+}
+
+// A detector matches one kind of secret. If group is set, only that capture group
+// of the match is the secret (ex. the value of an assignment).
+type detector struct {
+	kind       string
+	pattern    *regexp.Regexp
+	group      int
+	minEntropy float64
+}
+
+var detectors = []detector{
+	{kind: "PRIVATE_KEY", pattern: regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----[\s\S]*?-----END [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----`)},
+	{kind: "AWS_ACCESS_KEY", pattern: regexp.MustCompile(`\b(AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b`)},
+	{kind: "AWS_SECRET_KEY", pattern: regexp.MustCompile(`(?i)aws_?secret_?(access_?)?key["']?\s*[:=]+\s*["']?([A-Za-z0-9/+=]{40})\b`), group: 2},
+	{kind: "GOOGLE_API_KEY", pattern: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{kind: "GITHUB_TOKEN", pattern: regexp.MustCompile(`\b(ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36,}\b|\bgithub_pat_[A-Za-z0-9_]{22,}\b`)},
+	{kind: "SLACK_TOKEN", pattern: regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}\b`)},
+	{kind: "SLACK_WEBHOOK", pattern: regexp.MustCompile(`https://hooks\.slack\.com/services/[A-Za-z0-9/_]+`)},
+	{kind: "STRIPE_KEY", pattern: regexp.MustCompile(`\b(sk|rk)_live_[0-9A-Za-z]{24,}\b`)},
+	{kind: "CREV_API_KEY", pattern: regexp.MustCompile(`\bcsk_[0-9a-f]{64}\b`)},
+	{kind: "JWT", pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+	// Values assigned to names like "password" or "api_key" that look random enough to be real.
+	{
+		kind:       "SECRET",
+		pattern:    regexp.MustCompile(`(?i)[\w.-]*(secret|token|passw(or)?d|pwd|api_?key|access_?key|auth|credential)[\w.-]*["']?\s*[:=]+\s*["']?([^\s"'` + "`" + `(),;<>\[\]{}]{16,})`),
+		group:      3,
+		minEntropy: 3.5,
+	},
+}
+
+// A secret found in a single file.
+type match struct {
+	start, end int
+	kind       string
+}
+
+// Redact returns a copy of the given map of file paths to their content in which every
+// detected secret is replaced by a typed placeholder such as "[REDACTED:AWS_ACCESS_KEY]",
+// together with a finding for every redacted secret. Line numbers are preserved.
+func Redact(fileContentMap map[string]string) (map[string]string, []Finding) {
+	redacted := make(map[string]string, len(fileContentMap))
+	var findings []Finding
+	for path, content := range fileContentMap {
+		matches := scan(content)
+		var out strings.Builder
+		last := 0
+		for _, m := range matches {
+			findings = append(findings, Finding{
+				Path: path,
+				Line: strings.Count(content[:m.start], "\n") + 1,
+				Kind: m.kind,
+			})
+			out.WriteString(content[last:m.start])
+			out.WriteString(Placeholder(m.kind))
+			// Keep multi-line secrets, like private keys, from shifting the lines below them.
+			out.WriteString(strings.Repeat("\n", strings.Count(content[m.start:m.end], "\n")))
+			last = m.end
+		}
+		out.WriteString(content[last:])
+		redacted[path] = out.String()
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Path != findings[j].Path {
+			return findings[i].Path < findings[j].Path
+		}
+		return findings[i].Line < findings[j].Line
+	})
+	return redacted, findings
+}
+
+// Placeholder returns the text that replaces a secret of the given kind.
+func Placeholder(kind string) string {
+	return "[REDACTED:" + kind + "]"
+}
+
+// Returns the non-overlapping secrets in the content, ordered by position.
+func scan(content string) []match {
+	var matches []match
+	for _, d := range detectors {
+		for _, loc := range d.pattern.FindAllStringSubmatchIndex(content, -1) {
+			start, end := loc[2*d.group], loc[2*d.group+1]
+			if start < 0 {
+				continue
+			}
+			if d.minEntropy > 0 && !looksRandom(content[start:end], d.minEntropy) {
+				continue
+			}
+			matches = append(matches, match{start: start, end: end, kind: d.kind})
+		}
+	}
+	// When matches overlap the one that starts first wins. Of matches that start at the
+	// same offset, the one of the earlier, more specific detector wins.
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].start < matches[j].start })
+	var result []match
+	for _, m := range matches {
+		if len(result) > 0 && m.start < result[len(result)-1].end {
+			continue
+		}
+		result = append(result, m)
+	}
+	return result
+}
+
+// Returns true if the string mixes letters and digits and has at least the given entropy,
+// which separates generated credentials from identifiers like "production_api_key".
+func looksRandom(s string, minEntropy float64) bool {
+	return strings.ContainsAny(s, "0123456789") &&
+		strings.IndexFunc(s, unicode.IsLetter) >= 0 &&
+		entropy(s) >= minEntropy
+}
+
+// Returns the Shannon entropy of the string in bits per character.
+func entropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+	var result float64
+	n := float64(len(s))
+	for _, count := range counts {
+		p := float64(count) / n
+		result -= p * math.Log2(p)
+	}
+	return result
+}
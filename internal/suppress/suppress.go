@@ -0,0 +1,201 @@
+// Package suppress applies "crev:ignore <category> <reason>" comments, with which
+// developers mark code that a review flags as intentional.
+//
+// A comment on its own line suppresses findings on the next line of code, or in the
+// whole block if that line opens one. A comment that nothing follows in the block it
+// starts, as in an empty function, suppresses findings in that block, and a comment
+// at the end of a line of code only suppresses findings on that line. The category
+// "all" suppresses every category.
+package suppress
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/vossenwout/crev/pkg/review"
+)
+
+// Matches a suppression comment with its category and reason.
+var commentPattern = regexp.MustCompile(`crev:ignore(?:\s+([\w.-]+|\*))?(?:[ \t]+(.*))?`)
+
+// Matches the characters that start a comment before a suppression.
+var commentStart = regexp.MustCompile(`(//|#|--|/\*|<!--|;)\s*crev:ignore`)
+
+// Comment is a suppression comment and the lines it applies to.
+type Comment struct {
+	Path     string
+	Line     int
+	Category string
+	Reason   string
+	// StartLine and EndLine are the lines in which findings are suppressed.
+	StartLine int
+	EndLine   int
+}
+
+// Suppressed is a finding that has been suppressed by a comment.
+type Suppressed struct {
+	Finding review.Finding `json:"finding"`
+	Reason  string         `json:"reason"`
+	// Comment is the location of the comment, as "path:line".
+	Comment string `json:"comment"`
+}
+
+// Location returns the location of the comment as "path:line".
+func (c Comment) Location() string {
+	return fmt.Sprintf("%s:%d", c.Path, c.Line)
+}
+
+// Matches returns true if the comment suppresses the finding.
+func (c Comment) Matches(finding review.Finding) bool {
+	if finding.Path != c.Path || finding.StartLine < c.StartLine || finding.StartLine > c.EndLine {
+		return false
+	}
+	return c.Category == "all" || c.Category == "*" || strings.EqualFold(c.Category, finding.Category)
+}
+
+// Parse returns the suppression comments in the content of a file. Comments without a
+// category or reason are returned as errors, since every suppression must be explained.
+func Parse(path string, content string) ([]Comment, []error) {
+	lines := strings.Split(content, "\n")
+	var comments []Comment
+	var errs []error
+	for i, line := range lines {
+		if !commentStart.MatchString(line) {
+			continue
+		}
+		match := commentPattern.FindStringSubmatch(line)
+		category := strings.ToLower(match[1])
+		reason := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(match[2]), "*/"))
+		reason = strings.TrimSpace(strings.TrimSuffix(reason, "-->"))
+		if category == "" || reason == "" {
+			errs = append(errs, fmt.Errorf("%s:%d: crev:ignore needs a category and a reason", path, i+1))
+			continue
+		}
+		comment := Comment{Path: path, Line: i + 1, Category: category, Reason: reason}
+		comment.StartLine, comment.EndLine = scope(lines, i)
+		comments = append(comments, comment)
+	}
+	return comments, errs
+}
+
+// Returns the first and last line, starting at 1, to which the comment at index i applies.
+func scope(lines []string, i int) (int, int) {
+	code := strings.TrimSpace(lines[i][:commentStart.FindStringIndex(lines[i])[0]])
+	// A comment at the end of a line of code only applies to that line.
+	if code != "" {
+		return i + 1, i + 1
+	}
+	next := nextCode(lines, i)
+	// A comment that nothing follows in the block it starts, like a comment in an empty
+	// function, applies to that block.
+	if prev := previousCode(lines, i); prev >= 0 && opensBlock(lines[prev]) {
+		end := blockEnd(lines, prev)
+		if next < 0 || next > end || next == end && strings.HasPrefix(strings.TrimSpace(lines[next]), "}") {
+			return prev + 1, end + 1
+		}
+	}
+	// Otherwise it applies to the next line, or to the block that line opens.
+	if next < 0 {
+		return i + 1, i + 1
+	}
+	if opensBlock(lines[next]) {
+		return next + 1, blockEnd(lines, next) + 1
+	}
+	return next + 1, next + 1
+}
+
+// Returns the index of the first line after index i that is neither blank nor a
+// suppression comment, or -1 if there is none.
+func nextCode(lines []string, i int) int {
+	for j := i + 1; j < len(lines); j++ {
+		if strings.TrimSpace(lines[j]) != "" && !commentStart.MatchString(lines[j]) {
+			return j
+		}
+	}
+	return -1
+}
+
+// Returns the index of the last non-blank line before index i, or -1 if there is none.
+func previousCode(lines []string, i int) int {
+	for j := i - 1; j >= 0; j-- {
+		if strings.TrimSpace(lines[j]) != "" {
+			return j
+		}
+	}
+	return -1
+}
+
+// Returns true if the line opens a block with a brace or, as in Python, a colon.
+func opensBlock(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasSuffix(trimmed, "{") || strings.HasSuffix(trimmed, ":")
+}
+
+// Returns the index of the last line of the block opened at index start.
+func blockEnd(lines []string, start int) int {
+	if strings.HasSuffix(strings.TrimSpace(lines[start]), "{") {
+		depth := 0
+		for j := start; j < len(lines); j++ {
+			depth += strings.Count(lines[j], "{") - strings.Count(lines[j], "}")
+			if depth <= 0 {
+				return j
+			}
+		}
+		return len(lines) - 1
+	}
+	// Indented block: every following line that is blank or indented deeper.
+	indent := indentation(lines[start])
+	end := start
+	for j := start + 1; j < len(lines); j++ {
+		if strings.TrimSpace(lines[j]) == "" {
+			continue
+		}
+		if indentation(lines[j]) <= indent {
+			break
+		}
+		end = j
+	}
+	return end
+}
+
+// Returns the number of leading spaces and tabs of the line.
+func indentation(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// Apply removes the findings that are suppressed by comments in the contents of the
+// files and returns the remaining findings, the suppressed findings and the comments
+// that could not be applied because they lack a category or reason.
+func Apply(findings []review.Finding, contents map[string]string) ([]review.Finding, []Suppressed, []error) {
+	paths := make(map[string]bool)
+	for _, finding := range findings {
+		paths[finding.Path] = true
+	}
+	comments := make(map[string][]Comment)
+	var errs []error
+	for path := range paths {
+		parsed, parseErrs := Parse(path, contents[path])
+		comments[path] = parsed
+		errs = append(errs, parseErrs...)
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+
+	var kept []review.Finding
+	var suppressed []Suppressed
+	for _, finding := range findings {
+		matched := false
+		for _, comment := range comments[finding.Path] {
+			if comment.Matches(finding) {
+				suppressed = append(suppressed, Suppressed{Finding: finding, Reason: comment.Reason, Comment: comment.Location()})
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			kept = append(kept, finding)
+		}
+	}
+	return kept, suppressed, errs
+}
@@ -0,0 +1,8 @@
+// Package tokens estimates how many tokens a language model needs for a text.
+package tokens
+
+// Given a text, Range returns a lower and upper estimate of its number of tokens.
+// Source code averages between 3 and 4 characters per token.
+func Range(text string) (int, int) {
+	return len(text) / 4, len(text) / 3
+}
@@ -0,0 +1,363 @@
+// Package bundle turns a project directory into a crev bundle: a single text with the
+// directory structure followed by the content of every file. It is the library behind
+// the crev bundle command:
+//
+//	b, err := bundle.New(
+//		bundle.WithRoot("path/to/project"),
+//		bundle.WithIncludeExtensions(".go"),
+//		bundle.WithOutline(),
+//	).Bundle(ctx)
+//	if err != nil {
+//		return err
+//	}
+//	_, err = b.WriteTo(os.Stdout)
+package bundle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/vossenwout/crev/internal/files"
+	"github.com/vossenwout/crev/internal/formatting"
+	"github.com/vossenwout/crev/internal/outline"
+	"github.com/vossenwout/crev/internal/redaction"
+	"github.com/vossenwout/crev/internal/secrets"
+)
+
+// DefaultIgnorePrefixes are the prefixes of file and directory names that are ignored
+// unless WithoutDefaultIgnores is used: configuration, dependencies and build output.
+var DefaultIgnorePrefixes = []string{
+	// ignore .git, .idea, .vscode, etc.
+	".",
+	// ignore crev specific files
+	"crev",
+	// ignore go.mod, go.sum, etc.
+	"go",
+	"license",
+	// readme
+	"readme",
+	"README",
+	// poetry
+	"pyproject.toml",
+	"poetry.lock",
+	"venv",
+	// output files
+	"build",
+	"dist",
+	"out",
+	"target",
+	"bin",
+	// javascript
+	"node_modules",
+	"coverage",
+	"public",
+	"static",
+	"Thumbs.db",
+	"package",
+	"yarn.lock",
+	"package",
+	"tsconfig",
+	// next.js
+	"next.config",
+	"next-env",
+	// python
+	"__pycache__",
+	"logs",
+	// java
+	"gradle",
+	// c++
+	"CMakeLists",
+	// ruby
+	"vendor",
+	"Gemfile",
+	// php
+	"composer",
+	// tailwind
+	"tailwind",
+	"postcss",
+}
+
+// DefaultIgnoreExtensions are the extensions of non-text files that are ignored unless
+// WithoutDefaultIgnores is used.
+var DefaultIgnoreExtensions = []string{
+	".jpeg",
+	".jpg",
+	".png",
+	".gif",
+	".pdf",
+	".svg",
+	".ico",
+	".woff",
+	".woff2",
+	".eot",
+	".ttf",
+	".otf",
+}
+
+// DefaultMaxConcurrency is the number of files that are read at the same time unless
+// WithMaxConcurrency is used.
+const DefaultMaxConcurrency = 100
+
+// ErrSecretsFound is returned by Bundle when secrets are detected and WithFailOnSecrets is used.
+var ErrSecretsFound = errors.New("secrets found")
+
+// SecretFinding describes a secret that has been redacted from a file.
+type SecretFinding = secrets.Finding
+
+// RedactionRule is a custom rule whose matches are replaced by pseudonyms named after it.
+type RedactionRule = redaction.Rule
+
+// Transform modifies the map of file paths to their content before it is bundled.
+type Transform func(ctx context.Context, fileContentMap map[string]string) (map[string]string, error)
+
+// Option configures a Bundler.
+type Option func(*Bundler)
+
+// Bundler creates bundles of a directory. Create one with New.
+type Bundler struct {
+	root              string
+	paths             []string
+	ignorePrefixes    []string
+	ignoreExtensions  []string
+	includeExtensions []string
+	defaultIgnores    bool
+	outline           bool
+	lineNumbers       bool
+	failOnSecrets     bool
+	redactBuiltins    []string
+	redactRules       []RedactionRule
+	transforms        []Transform
+	maxConcurrency    int
+}
+
+// Bundle is the result of bundling a directory.
+type Bundle struct {
+	// Tree is the directory structure of the bundled files.
+	Tree string
+	// Files maps the path of every file, relative to the root, to its bundled content.
+	Files map[string]string
+	// Secrets lists the secrets that have been redacted.
+	Secrets []SecretFinding
+	// Redactions is the number of redacted matches per redaction rule.
+	Redactions map[string]int
+}
+
+// New returns a bundler for the current directory configured by the options.
+func New(opts ...Option) *Bundler {
+	b := &Bundler{root: ".", defaultIgnores: true, maxConcurrency: DefaultMaxConcurrency}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+// WithRoot bundles the given directory instead of the current one.
+func WithRoot(dir string) Option {
+	return func(b *Bundler) { b.root = dir }
+}
+
+// WithPaths only bundles the given files and directories, relative to the root. Files that
+// are given explicitly are bundled even if the filters would ignore them.
+func WithPaths(paths ...string) Option {
+	return func(b *Bundler) { b.paths = append(b.paths, paths...) }
+}
+
+// WithIgnorePrefixes ignores files and directories whose name starts with one of the prefixes.
+func WithIgnorePrefixes(prefixes ...string) Option {
+	return func(b *Bundler) { b.ignorePrefixes = append(b.ignorePrefixes, prefixes...) }
+}
+
+// WithIgnoreExtensions ignores files with one of the extensions (ex. ".txt").
+func WithIgnoreExtensions(extensions ...string) Option {
+	return func(b *Bundler) { b.ignoreExtensions = append(b.ignoreExtensions, extensions...) }
+}
+
+// WithIncludeExtensions only includes files with one of the extensions (ex. ".go").
+func WithIncludeExtensions(extensions ...string) Option {
+	return func(b *Bundler) { b.includeExtensions = append(b.includeExtensions, extensions...) }
+}
+
+// WithoutDefaultIgnores stops ignoring DefaultIgnorePrefixes and DefaultIgnoreExtensions.
+func WithoutDefaultIgnores() Option {
+	return func(b *Bundler) { b.defaultIgnores = false }
+}
+
+// WithOutline reduces files in supported languages to their declarations.
+func WithOutline() Option {
+	return func(b *Bundler) { b.outline = true }
+}
+
+// WithLineNumbers prefixes every line of the bundled files with its number, so that answers
+// can cite lines. Combined with WithOutline, the numbers are those of the outline.
+func WithLineNumbers() Option {
+	return func(b *Bundler) { b.lineNumbers = true }
+}
+
+// WithFailOnSecrets makes Bundle return ErrSecretsFound when secrets are detected.
+// Secrets are always redacted.
+func WithFailOnSecrets() Option {
+	return func(b *Bundler) { b.failOnSecrets = true }
+}
+
+// WithRedaction replaces personal data matched by the built-in rules (ex. "email")
+// and the custom rules by pseudonyms.
+func WithRedaction(builtins []string, rules ...RedactionRule) Option {
+	return func(b *Bundler) {
+		b.redactBuiltins = append(b.redactBuiltins, builtins...)
+		b.redactRules = append(b.redactRules, rules...)
+	}
+}
+
+// WithTransform applies the transform to the files after they are read, outlined and
+// redacted. Transforms are applied in the order they are given.
+func WithTransform(transform Transform) Option {
+	return func(b *Bundler) { b.transforms = append(b.transforms, transform) }
+}
+
+// WithMaxConcurrency limits the number of files that are read at the same time.
+// A limit of zero or less uses DefaultMaxConcurrency.
+func WithMaxConcurrency(n int) Option {
+	return func(b *Bundler) {
+		if n <= 0 {
+			n = DefaultMaxConcurrency
+		}
+		b.maxConcurrency = n
+	}
+}
+
+// Bundle reads the files of the root directory and creates a bundle of them. When
+// WithFailOnSecrets is used and secrets are found, the bundle is returned together
+// with ErrSecretsFound so the caller can report them.
+func (b *Bundler) Bundle(ctx context.Context) (*Bundle, error) {
+	prefixesToIgnore := slices.Clone(b.ignorePrefixes)
+	extensionsToIgnore := slices.Clone(b.ignoreExtensions)
+	if b.defaultIgnores {
+		prefixesToIgnore = append(prefixesToIgnore, DefaultIgnorePrefixes...)
+		extensionsToIgnore = append(extensionsToIgnore, DefaultIgnoreExtensions...)
+	}
+	filePaths, err := b.filePaths(prefixesToIgnore, extensionsToIgnore)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// get the content of all files
+	fileContentMap, err := files.GetContentMapOfFiles(filePaths, b.maxConcurrency)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// use paths relative to the root in the bundle
+	relativePaths := make([]string, 0, len(filePaths))
+	for _, path := range filePaths {
+		relativePaths = append(relativePaths, b.relative(path))
+	}
+	relativeContentMap := make(map[string]string, len(fileContentMap))
+	for path, content := range fileContentMap {
+		relativeContentMap[b.relative(path)] = content
+	}
+	fileContentMap = relativeContentMap
+
+	if len(b.paths) > 0 {
+		// the given paths can be deep in the root, show the directories that lead to them
+		relativePaths = formatting.TreePaths(relativePaths)
+	}
+	result := &Bundle{Tree: formatting.GeneratePathTree(relativePaths)}
+
+	// reduce supported files to their declarations
+	if b.outline {
+		fileContentMap = outline.ContentMap(fileContentMap)
+	}
+
+	// redact secrets so they never end up in the bundle
+	fileContentMap, result.Secrets = secrets.Redact(fileContentMap)
+
+	// replace personal and customer data by pseudonyms
+	if len(b.redactBuiltins) > 0 || len(b.redactRules) > 0 {
+		redactor, err := redaction.NewRedactor(b.redactBuiltins, b.redactRules)
+		if err != nil {
+			return nil, err
+		}
+		fileContentMap, result.Redactions = redactor.Redact(fileContentMap)
+	}
+
+	// number the lines after redaction, which can change them
+	if b.lineNumbers {
+		for path, content := range fileContentMap {
+			fileContentMap[path] = formatting.NumberLines(content)
+		}
+	}
+
+	for _, transform := range b.transforms {
+		fileContentMap, err = transform(ctx, fileContentMap)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result.Files = fileContentMap
+
+	if b.failOnSecrets && len(result.Secrets) > 0 {
+		return result, fmt.Errorf("%w: %d secrets were detected", ErrSecretsFound, len(result.Secrets))
+	}
+	return result, nil
+}
+
+// Returns the paths of the files and directories to bundle.
+func (b *Bundler) filePaths(prefixesToIgnore []string, extensionsToIgnore []string) ([]string, error) {
+	if len(b.paths) == 0 {
+		return files.GetAllFilePaths(b.root, prefixesToIgnore, b.includeExtensions, extensionsToIgnore)
+	}
+	var filePaths []string
+	seen := make(map[string]bool)
+	for _, path := range b.paths {
+		path = filepath.Join(b.root, path)
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		paths := []string{path}
+		if info.IsDir() {
+			paths, err = files.GetAllFilePaths(path, prefixesToIgnore, b.includeExtensions, extensionsToIgnore)
+			if err != nil {
+				return nil, err
+			}
+		}
+		for _, p := range paths {
+			if !seen[p] {
+				seen[p] = true
+				filePaths = append(filePaths, p)
+			}
+		}
+	}
+	return filePaths, nil
+}
+
+// Returns the path relative to the root of the bundler.
+func (b *Bundler) relative(path string) string {
+	if rel, err := filepath.Rel(b.root, path); err == nil {
+		return rel
+	}
+	return path
+}
+
+// String returns the bundle in the crev-project.txt format.
+func (b *Bundle) String() string {
+	return formatting.CreateProjectString(b.Tree, b.Files)
+}
+
+// WriteTo writes the bundle in the crev-project.txt format to w.
+func (b *Bundle) WriteTo(w io.Writer) (int64, error) {
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
@@ -0,0 +1,149 @@
+// Contains the provider for the Anthropic Messages API.
+package review
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	anthropicBaseURL   = "https://api.anthropic.com"
+	anthropicModel     = "claude-3-5-sonnet-latest"
+	anthropicVersion   = "2023-06-01"
+	anthropicMaxTokens = 8192
+)
+
+type anthropicRequest struct {
+	Model     string    `json:"model"`
+	MaxTokens int       `json:"max_tokens"`
+	System    string    `json:"system,omitempty"`
+	Messages  []Message `json:"messages"`
+	Stream    bool      `json:"stream,omitempty"`
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+}
+
+type anthropicStreamEvent struct {
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// anthropicProvider sends code to the Anthropic Messages API.
+type anthropicProvider struct {
+	client  *httpClient
+	baseURL string
+	model   string
+	apiKey  string
+	// System prompt of reviews, including the instructions of the options.
+	prompt string
+}
+
+func newAnthropicProvider(opts Options, client *httpClient) *anthropicProvider {
+	return &anthropicProvider{
+		client:  client,
+		baseURL: baseURLOr(opts, anthropicBaseURL),
+		model:   modelOr(opts, anthropicModel),
+		apiKey:  opts.APIKey,
+		prompt:  reviewSystemPrompt(opts.Instructions),
+	}
+}
+
+func (p *anthropicProvider) Name() string { return "anthropic" }
+
+func (p *anthropicProvider) Destination() string {
+	return p.baseURL + "/v1/messages (" + p.model + ")"
+}
+
+func (p *anthropicProvider) Review(ctx context.Context, codeToReview string) (string, error) {
+	return p.complete(ctx, p.prompt, []Message{{Role: "user", Content: codeToReview}})
+}
+
+func (p *anthropicProvider) ReviewStream(ctx context.Context, codeToReview string, w io.Writer) (string, error) {
+	return p.completeStream(ctx, p.prompt, []Message{{Role: "user", Content: codeToReview}}, w)
+}
+
+func (p *anthropicProvider) Chat(ctx context.Context, system string, messages []Message, w io.Writer) (string, error) {
+	if w == nil {
+		return p.complete(ctx, system, messages)
+	}
+	return p.completeStream(ctx, system, messages, w)
+}
+
+// Returns the request for the conversation with the system prompt and its headers.
+func (p *anthropicProvider) request(system string, messages []Message, stream bool) (anthropicRequest, map[string]string) {
+	input := anthropicRequest{
+		Model:     p.model,
+		MaxTokens: anthropicMaxTokens,
+		System:    system,
+		Messages:  messages,
+		Stream:    stream,
+	}
+	headers := map[string]string{
+		"x-api-key":         p.apiKey,
+		"anthropic-version": anthropicVersion,
+	}
+	return input, headers
+}
+
+// Sends the conversation with the system prompt and returns the reply of the model.
+func (p *anthropicProvider) complete(ctx context.Context, system string, messages []Message) (string, error) {
+	input, headers := p.request(system, messages, false)
+	var output anthropicResponse
+	if err := p.client.postJSON(ctx, p.baseURL+"/v1/messages", input, headers, &output); err != nil {
+		return "", err
+	}
+	var text strings.Builder
+	for _, block := range output.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return "", fmt.Errorf("%s returned no text", p.baseURL)
+	}
+	return text.String(), nil
+}
+
+// Sends the conversation with the system prompt and writes the reply of the model to w
+// as it is streamed back.
+func (p *anthropicProvider) completeStream(ctx context.Context, system string, messages []Message, w io.Writer) (string, error) {
+	input, headers := p.request(system, messages, true)
+	resp, err := p.client.postStream(ctx, p.baseURL+"/v1/messages", input, headers)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var reply strings.Builder
+	err = readEvents(resp.Body, func(event string, data string) (bool, error) {
+		var streamEvent anthropicStreamEvent
+		if err := json.Unmarshal([]byte(data), &streamEvent); err != nil {
+			return false, fmt.Errorf("error decoding stream of %s: %w", p.baseURL, err)
+		}
+		switch event {
+		case "message_stop":
+			return true, nil
+		case "error":
+			return false, fmt.Errorf("%w: %s", ErrStreamInterrupted, streamEvent.Error.Message)
+		case "content_block_delta":
+			if streamEvent.Delta.Type == "text_delta" {
+				return false, writeChunk(w, &reply, streamEvent.Delta.Text)
+			}
+		}
+		return false, nil
+	})
+	return reply.String(), err
+}
@@ -0,0 +1,251 @@
+// Contains code to review bundles that are too large for a single request in chunks.
+package review
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/vossenwout/crev/internal/formatting"
+	"github.com/vossenwout/crev/internal/tokens"
+)
+
+// DefaultConcurrency is the number of chunks that are reviewed at the same time unless
+// Options.Concurrency is set.
+const DefaultConcurrency = 4
+
+// Introduces every chunk, so the reviewer knows it only sees part of the project.
+const chunkHeader = `This is part %d of %d of a larger project, the other parts are reviewed separately.
+Only review the files in this part.
+
+`
+
+// Heading of the directory structure that starts the project string of a bundle.
+const projectHeading = "Project Directory Structure:\n"
+
+// The instructions chat models receive to combine the reviews of the chunks.
+const synthesisPrompt = `You are an experienced software engineer. A project was too large to review at once, so
+its parts were reviewed separately. The user sends the reviews of all parts.
+
+Combine them into a single coherent code review in markdown. Start with a short summary of the whole
+project, then list the issues grouped by file. Issues raised in several parts must only be listed once.
+Refer to files by their path, explain why something is a problem and suggest how to fix it.
+End with the most important improvements to make first. Do not add a crev-findings block.`
+
+// Chunk is a part of a bundle that is reviewed on its own.
+type Chunk struct {
+	// Files are the paths of the files in the chunk.
+	Files []string
+	// Bundle is the chunk in the format of a bundle.
+	Bundle string
+}
+
+// SplitBundle partitions the files of a bundle into chunks of at most maxTokens estimated
+// tokens. Files of the same directory are kept together when they fit, and a file that is
+// larger than maxTokens gets a chunk of its own. Text before the directory structure of the
+// bundle, like the framing of a change, starts every chunk.
+func SplitBundle(bundle string, maxTokens int) []Chunk {
+	preamble, _, found := strings.Cut(bundle, projectHeading)
+	if !found {
+		preamble = ""
+	}
+	fileContentMap := formatting.ParseProjectString(bundle)
+	paths := make([]string, 0, len(fileContentMap))
+	for path := range fileContentMap {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	// Files grouped by directory, in the order of the paths.
+	var groups [][]string
+	for i, path := range paths {
+		if i == 0 || filepath.Dir(path) != filepath.Dir(paths[i-1]) {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], path)
+	}
+
+	size := func(path string) int {
+		_, maxFileTokens := tokens.Range(fileContentMap[path])
+		return maxFileTokens
+	}
+	var parts [][]string
+	used := maxTokens
+	add := func(files []string, tokens int) {
+		if len(parts) == 0 || used+tokens > maxTokens {
+			parts = append(parts, nil)
+			used = 0
+		}
+		parts[len(parts)-1] = append(parts[len(parts)-1], files...)
+		used += tokens
+	}
+	for _, group := range groups {
+		groupTokens := 0
+		for _, path := range group {
+			groupTokens += size(path)
+		}
+		if groupTokens <= maxTokens {
+			add(group, groupTokens)
+			continue
+		}
+		for _, path := range group {
+			add([]string{path}, size(path))
+		}
+	}
+
+	chunks := make([]Chunk, 0, len(parts))
+	for i, files := range parts {
+		chunkFiles := make(map[string]string, len(files))
+		for _, path := range files {
+			chunkFiles[path] = fileContentMap[path]
+		}
+		tree := formatting.GeneratePathTree(formatting.TreePaths(files))
+		chunks = append(chunks, Chunk{
+			Files:  files,
+			Bundle: preamble + fmt.Sprintf(chunkHeader, i+1, len(parts)) + formatting.CreateProjectString(tree, chunkFiles),
+		})
+	}
+	return chunks
+}
+
+// Reviews the chunks concurrently and combines their reviews into one.
+func reviewChunks(ctx context.Context, provider Provider, chunks []Chunk, lineCounts map[string]int, opts Options) (*Result, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	reviews := make([]string, len(chunks))
+	errs := make([]error, len(chunks))
+	semaphore := make(chan struct{}, concurrency)
+	var mu sync.Mutex
+	done := 0
+	var wg sync.WaitGroup
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			if err := ctx.Err(); err != nil {
+				errs[i] = err
+				return
+			}
+			reviews[i], errs[i] = provider.Review(ctx, chunk.Bundle)
+			if errs[i] != nil {
+				errs[i] = fmt.Errorf("error reviewing part %d of %d: %w", i+1, len(chunks), errs[i])
+				// Reviewing the other chunks is pointless if one of them fails.
+				cancel()
+				return
+			}
+			if opts.OnChunk != nil {
+				mu.Lock()
+				done++
+				opts.OnChunk(done, len(chunks))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	// Report the error that made the review fail rather than the cancellations it caused.
+	var firstErr error
+	for _, err := range errs {
+		if err != nil && (firstErr == nil || errors.Is(firstErr, context.Canceled) && !errors.Is(err, context.Canceled)) {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	result := &Result{Provider: provider.Name(), Chunks: len(chunks)}
+	markdowns := make([]string, len(chunks))
+	var findings []Finding
+	for i, review := range reviews {
+		if !listsFindings(review) {
+			result.FindingsMissing = true
+		}
+		markdown, chunkFindings, err := ParseFindings(review)
+		if err != nil {
+			// Keep the findings of the other chunks.
+			result.FindingsError = err
+			markdowns[i] = review
+			continue
+		}
+		markdowns[i] = markdown
+		findings = append(findings, chunkFindings...)
+	}
+	result.Findings, result.InvalidFindings = ValidateFindings(findings, lineCounts)
+	result.Findings = DeduplicateFindings(result.Findings)
+
+	review, err := synthesize(ctx, provider, chunks, markdowns, opts.Stream)
+	result.Review = review
+	if err != nil && review == "" {
+		return nil, err
+	}
+	return result, err
+}
+
+// Combines the reviews of the chunks into one. Chat models write a new review, for other
+// providers the reviews of the chunks are put one after the other.
+func synthesize(ctx context.Context, provider Provider, chunks []Chunk, reviews []string, w io.Writer) (string, error) {
+	var sb strings.Builder
+	for i, review := range reviews {
+		fmt.Fprintf(&sb, "## Part %d of %d: %s\n\n%s\n\n", i+1, len(reviews), describeFiles(chunks[i].Files), strings.TrimSpace(review))
+	}
+	chat, ok := provider.(ChatProvider)
+	if !ok {
+		review := fmt.Sprintf("# Code review\n\nThe project was reviewed in %d parts.\n\n", len(reviews)) + sb.String()
+		if w != nil {
+			if _, err := io.WriteString(w, review); err != nil {
+				return review, err
+			}
+		}
+		return review, nil
+	}
+	return chat.Chat(ctx, synthesisPrompt, []Message{{Role: "user", Content: sb.String()}}, w)
+}
+
+// Returns a short description of the files of a chunk: their directories.
+func describeFiles(files []string) string {
+	var dirs []string
+	for i, path := range files {
+		dir := filepath.Dir(path)
+		if i == 0 || dir != filepath.Dir(files[i-1]) {
+			dirs = append(dirs, dir)
+		}
+	}
+	if len(dirs) > 5 {
+		dirs = append(dirs[:5], fmt.Sprintf("and %d more", len(dirs)-5))
+	}
+	return strings.Join(dirs, ", ")
+}
+
+// DeduplicateFindings removes findings about the same issue raised more than once: those
+// in the same file and category whose lines overlap. The most serious one is kept.
+func DeduplicateFindings(findings []Finding) []Finding {
+	sorted := append([]Finding(nil), findings...)
+	SortFindings(sorted)
+	var result []Finding
+	for _, finding := range sorted {
+		duplicate := false
+		for _, kept := range result {
+			if kept.Path == finding.Path && strings.EqualFold(kept.Category, finding.Category) &&
+				finding.StartLine <= kept.EndLine && kept.StartLine <= finding.EndLine {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			result = append(result, finding)
+		}
+	}
+	return result
+}
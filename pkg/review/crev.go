@@ -0,0 +1,104 @@
+// Contains the provider for the crev review service.
+package review
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type ReviewInput struct {
+	Code   string `json:"code"`
+	Stream bool   `json:"stream,omitempty"`
+	// Findings asks the service to return structured findings next to the review.
+	Findings bool `json:"findings,omitempty"`
+	// Instructions are conventions of the team the review must check, in addition to
+	// the instructions of the service.
+	Instructions string `json:"instructions,omitempty"`
+}
+
+type ReviewOutput struct {
+	Review   string    `json:"review"`
+	Findings []Finding `json:"findings,omitempty"`
+}
+
+// Returns the review with the findings the service returned separately, if any, in the
+// crev-findings block other providers write.
+func (o ReviewOutput) withFindings() string {
+	if len(o.Findings) == 0 {
+		return o.Review
+	}
+	return strings.TrimRight(o.Review, "\n") + "\n\n" + FormatFindings(o.Findings)
+}
+
+const reviewURL = "https://reviewcode-qcgl4feadq-uc.a.run.app"
+
+// crevProvider sends code to the crev review service, which holds the review prompt.
+type crevProvider struct {
+	client       *httpClient
+	url          string
+	apiKey       string
+	instructions string
+}
+
+func newCrevProvider(opts Options, client *httpClient) *crevProvider {
+	return &crevProvider{
+		client: client,
+		url:    baseURLOr(opts, reviewURL),
+		apiKey: opts.APIKey,
+		// The service adds the instructions to its own review prompt.
+		instructions: opts.Instructions,
+	}
+}
+
+func (p *crevProvider) Name() string { return "crev" }
+
+func (p *crevProvider) Destination() string { return p.url }
+
+func (p *crevProvider) Review(ctx context.Context, codeToReview string) (string, error) {
+	var output ReviewOutput
+	err := p.client.postJSON(ctx, p.url, ReviewInput{Code: codeToReview, Findings: true, Instructions: p.instructions}, p.headers(), &output)
+	if err != nil {
+		return "", err
+	}
+	return output.withFindings(), nil
+}
+
+// ReviewStream asks the service for a stream of server-sent events that each carry a
+// chunk of the review. Servers that do not stream respond with the complete review.
+func (p *crevProvider) ReviewStream(ctx context.Context, codeToReview string, w io.Writer) (string, error) {
+	resp, err := p.client.postStream(ctx, p.url, ReviewInput{Code: codeToReview, Stream: true, Findings: true, Instructions: p.instructions}, p.headers())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var review strings.Builder
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		var output ReviewOutput
+		if err := json.NewDecoder(resp.Body).Decode(&output); err != nil {
+			return "", fmt.Errorf("error decoding response of %s: %w", p.url, err)
+		}
+		return output.withFindings(), writeChunk(w, &review, output.withFindings())
+	}
+	err = readEvents(resp.Body, func(event string, data string) (bool, error) {
+		switch event {
+		case "done":
+			return true, nil
+		case "error":
+			return false, fmt.Errorf("%w: %s", ErrStreamInterrupted, data)
+		}
+		var chunk ReviewOutput
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return false, fmt.Errorf("error decoding stream of %s: %w", p.url, err)
+		}
+		return false, writeChunk(w, &review, chunk.withFindings())
+	})
+	return review.String(), err
+}
+
+func (p *crevProvider) headers() map[string]string {
+	return map[string]string{"api-key": p.apiKey}
+}
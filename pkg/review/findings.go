@@ -0,0 +1,264 @@
+// Contains the structured findings of a review and the code to parse and validate them.
+package review
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/vossenwout/crev/internal/formatting"
+)
+
+// Severity is how serious a finding is.
+type Severity string
+
+// Severities from most to least serious.
+const (
+	SeverityCritical Severity = "critical"
+	SeverityHigh     Severity = "high"
+	SeverityMedium   Severity = "medium"
+	SeverityLow      Severity = "low"
+	SeverityInfo     Severity = "info"
+)
+
+// Severities lists every severity from most to least serious.
+var Severities = []Severity{SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow, SeverityInfo}
+
+// ParseSeverity returns the severity with the given name, ignoring case.
+func ParseSeverity(name string) (Severity, error) {
+	for _, severity := range Severities {
+		if strings.EqualFold(name, string(severity)) {
+			return severity, nil
+		}
+	}
+	return "", fmt.Errorf("unknown severity %q, available severities: critical, high, medium, low, info", name)
+}
+
+// AtLeast returns true if the severity is as serious as the threshold or more.
+func (s Severity) AtLeast(threshold Severity) bool {
+	return s.rank() <= threshold.rank()
+}
+
+// Returns the position of the severity in Severities, unknown severities rank last.
+func (s Severity) rank() int {
+	for i, severity := range Severities {
+		if s == severity {
+			return i
+		}
+	}
+	return len(Severities)
+}
+
+// Finding is a single issue raised by a review.
+type Finding struct {
+	// Path is the path of the file as it appears in the bundle.
+	Path string `json:"path"`
+	// StartLine and EndLine are the lines the finding is about, starting at 1.
+	// Both are 0 for findings about the file as a whole.
+	StartLine int `json:"start_line"`
+	EndLine   int `json:"end_line"`
+	// Severity is how serious the issue is.
+	Severity Severity `json:"severity"`
+	// Category is the kind of issue, ex. "bug", "security" or "performance".
+	Category string `json:"category"`
+	// Message explains the issue.
+	Message string `json:"message"`
+	// Suggestion explains how to fix the issue, if the reviewer has one.
+	Suggestion string `json:"suggestion,omitempty"`
+	// Fingerprint identifies the finding across reviews. It is not set by reviewers.
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// String returns the finding on a single line, as "path:line: severity [category] message".
+func (f Finding) String() string {
+	location := f.Path
+	switch {
+	case f.StartLine > 0 && f.EndLine > f.StartLine:
+		location += fmt.Sprintf(":%d-%d", f.StartLine, f.EndLine)
+	case f.StartLine > 0:
+		location += fmt.Sprintf(":%d", f.StartLine)
+	}
+	category := ""
+	if f.Category != "" {
+		category = " [" + f.Category + "]"
+	}
+	return fmt.Sprintf("%s: %s%s %s", location, f.Severity, category, strings.Join(strings.Fields(f.Message), " "))
+}
+
+// SortFindings orders findings from most to least serious, then by path and line.
+func SortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Severity.rank() != b.Severity.rank() {
+			return a.Severity.rank() < b.Severity.rank()
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.StartLine < b.StartLine
+	})
+}
+
+// InvalidFinding is a finding that does not match the bundled files.
+type InvalidFinding struct {
+	Finding Finding `json:"finding"`
+	Reason  string  `json:"reason"`
+}
+
+// Language of the fenced code block in which reviewers list their findings.
+const findingsFence = "```crev-findings"
+
+// The instructions chat models receive to list their findings in a way that can be parsed.
+const findingsPrompt = `After the review, list every issue you raised as a finding in a fenced code block with the
+language crev-findings. The block contains a single JSON object of the form:
+` + findingsFence + `
+{"findings": [{"path": "cmd/main.go", "start_line": 12, "end_line": 14, "severity": "high", "category": "bug", "message": "What is wrong and why.", "suggestion": "How to fix it."}]}
+` + "```" + `
+The path is the path of a bundled file and the lines are lines of its content, where the line after
+"Content:" is line 1. Use 0 for both lines if the finding is about the file as a whole. The severity
+is one of critical, high, medium, low or info and the category is one of bug, security, performance,
+maintainability, style or testing. The block must be the last thing in your answer.`
+
+// Matches the block of findings and everything after it.
+var findingsBlock = regexp.MustCompile("(?s)\n?" + regexp.QuoteMeta(findingsFence) + "[ \t]*\n(.*?)(\n```|$)")
+
+// ParseFindings splits a review into its markdown and the findings listed in its
+// crev-findings block. A review without a block has no findings.
+func ParseFindings(review string) (string, []Finding, error) {
+	loc := findingsBlock.FindStringSubmatchIndex(review)
+	if loc == nil {
+		return review, nil, nil
+	}
+	markdown := strings.TrimRight(review[:loc[0]], " \t\n") + "\n"
+	var block struct {
+		Findings []Finding `json:"findings"`
+	}
+	if err := json.Unmarshal([]byte(review[loc[2]:loc[3]]), &block); err != nil {
+		return markdown, nil, fmt.Errorf("error decoding the findings of the review: %w", err)
+	}
+	return markdown, block.Findings, nil
+}
+
+// Reports whether the review lists its findings in a crev-findings block.
+func listsFindings(review string) bool {
+	return findingsBlock.MatchString(review)
+}
+
+// FormatFindings returns a crev-findings block listing the findings, as reviewers write it.
+func FormatFindings(findings []Finding) string {
+	if findings == nil {
+		findings = []Finding{}
+	}
+	data, _ := json.Marshal(map[string][]Finding{"findings": findings})
+	return findingsFence + "\n" + string(data) + "\n```\n"
+}
+
+// LineCounts returns the number of lines of every file in a bundle. A trailing newline
+// ends the last line rather than starting another one.
+func LineCounts(bundle string) map[string]int {
+	lineCounts := make(map[string]int)
+	for path, content := range formatting.ParseProjectString(bundle) {
+		lineCounts[path] = strings.Count(content, "\n")
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			lineCounts[path]++
+		}
+	}
+	return lineCounts
+}
+
+// ValidateFindings checks the findings against the line counts of the bundled files.
+// Findings about unknown files or lines, without a message or with an unknown severity
+// are invalid. A line count of 0 means the lines of the file cannot be checked.
+// Severities are normalized to lower case and a missing end line is set to the start line.
+func ValidateFindings(findings []Finding, lineCounts map[string]int) ([]Finding, []InvalidFinding) {
+	var valid []Finding
+	var invalid []InvalidFinding
+	for _, finding := range findings {
+		if reason := validateFinding(&finding, lineCounts); reason != "" {
+			invalid = append(invalid, InvalidFinding{Finding: finding, Reason: reason})
+			continue
+		}
+		valid = append(valid, finding)
+	}
+	return valid, invalid
+}
+
+// Normalizes the finding and returns why it is invalid, or an empty string if it is valid.
+func validateFinding(finding *Finding, lineCounts map[string]int) string {
+	lineCount, ok := lineCounts[finding.Path]
+	if !ok {
+		return fmt.Sprintf("file %s is not part of the bundle", finding.Path)
+	}
+	severity, err := ParseSeverity(string(finding.Severity))
+	if err != nil {
+		return err.Error()
+	}
+	finding.Severity = severity
+	if strings.TrimSpace(finding.Message) == "" {
+		return "the finding has no message"
+	}
+	if finding.EndLine == 0 {
+		finding.EndLine = finding.StartLine
+	}
+	switch {
+	case finding.StartLine < 0 || (finding.StartLine == 0 && finding.EndLine != 0):
+		return fmt.Sprintf("invalid start line %d", finding.StartLine)
+	case finding.EndLine < finding.StartLine:
+		return fmt.Sprintf("end line %d is before start line %d", finding.EndLine, finding.StartLine)
+	case lineCount > 0 && finding.StartLine > lineCount:
+		return fmt.Sprintf("line %d is past the end of %s, which has %d lines", finding.StartLine, finding.Path, lineCount)
+	}
+	// Reviewers sometimes point one line past the end of a file.
+	if lineCount > 0 && finding.EndLine > lineCount {
+		finding.EndLine = lineCount
+	}
+	return ""
+}
+
+// Writer that forwards a streamed review but holds back its crev-findings block, so only
+// the readable part of the review is shown while it is written.
+type hideFindingsWriter struct {
+	w io.Writer
+	// Text that may be the start of the block and has not been forwarded yet.
+	pending string
+	hidden  bool
+}
+
+func (h *hideFindingsWriter) Write(p []byte) (int, error) {
+	if h.hidden {
+		return len(p), nil
+	}
+	text := h.pending + string(p)
+	h.pending = ""
+	if i := strings.Index(text, findingsFence); i >= 0 {
+		h.hidden = true
+		_, err := io.WriteString(h.w, strings.TrimRight(text[:i], " \t\n"))
+		return len(p), err
+	}
+	// Hold back a suffix that could be the start of the fence, and the blank lines before it.
+	for n := min(len(findingsFence)-1, len(text)); n > 0; n-- {
+		if strings.HasPrefix(findingsFence, text[len(text)-n:]) {
+			h.pending = text[len(text)-n:]
+			text = text[:len(text)-n]
+			break
+		}
+	}
+	trimmed := strings.TrimRight(text, " \t\n")
+	h.pending = text[len(trimmed):] + h.pending
+	text = trimmed
+	_, err := io.WriteString(h.w, text)
+	return len(p), err
+}
+
+// Flush forwards text that was held back but turned out not to be the start of the block.
+func (h *hideFindingsWriter) Flush() error {
+	if h.hidden || h.pending == "" {
+		return nil
+	}
+	_, err := io.WriteString(h.w, h.pending)
+	h.pending = ""
+	return err
+}
@@ -0,0 +1,354 @@
+// Contains the HTTP plumbing shared by the review providers.
+package review
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// HTTPOptions configures how requests are sent to the review provider.
+type HTTPOptions struct {
+	// Timeout limits the duration of a request, zero means no timeout.
+	Timeout time.Duration
+	// Proxy is the URL of an HTTP(S) proxy. By default the HTTPS_PROXY and
+	// HTTP_PROXY environment variables are used.
+	Proxy string
+	// CACert is the path to a PEM bundle of certificate authorities to trust
+	// in addition to the system ones.
+	CACert string
+	// ClientCert and ClientKey are the paths to a PEM certificate and key used
+	// to authenticate with TLS client certificates.
+	ClientCert string
+	ClientKey  string
+	// Headers are added to every request.
+	Headers map[string]string
+	// MaxAttempts is the number of times a request is sent before a transient
+	// failure is returned, zero means 4 attempts.
+	MaxAttempts int
+	// RetryDelay is the delay before the first retry, which doubles after every
+	// attempt. Zero means one second.
+	RetryDelay time.Duration
+	// OnRetry, if set, is called before a failed request is retried.
+	OnRetry func(err error, delay time.Duration, attempt int)
+}
+
+const (
+	defaultMaxAttempts = 4
+	defaultRetryDelay  = time.Second
+	maxRetryDelay      = 30 * time.Second
+	// Longest Retry-After the client is willing to wait for.
+	maxRetryAfter = 5 * time.Minute
+)
+
+// Errors that errors returned by Review can be compared with using errors.Is.
+var (
+	// ErrUnauthorized means the API key was missing or rejected.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrQuota means the account has run out of credits or quota.
+	ErrQuota = errors.New("quota exceeded")
+	// ErrTooLarge means the bundle exceeds what the provider or model accepts.
+	ErrTooLarge = errors.New("bundle too large")
+)
+
+// StatusError is returned when a review service responds with an unexpected status code.
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	switch {
+	case e.Unauthorized():
+		return fmt.Sprintf("unauthorized: %s rejected the API key (status code %d): %s", e.URL, e.StatusCode, e.Body)
+	case e.QuotaExceeded():
+		return fmt.Sprintf("quota exceeded: %s refused the request (status code %d): %s", e.URL, e.StatusCode, e.Body)
+	case e.TooLarge():
+		return fmt.Sprintf("bundle too large: %s refused the request (status code %d): %s", e.URL, e.StatusCode, e.Body)
+	}
+	return fmt.Sprintf("%s responded with status code %d: %s", e.URL, e.StatusCode, e.Body)
+}
+
+// Unwrap returns the typed error that matches the status, if any.
+func (e *StatusError) Unwrap() error {
+	switch {
+	case e.Unauthorized():
+		return ErrUnauthorized
+	case e.QuotaExceeded():
+		return ErrQuota
+	case e.TooLarge():
+		return ErrTooLarge
+	}
+	return nil
+}
+
+// Unauthorized returns true if the API key was missing or rejected.
+func (e *StatusError) Unauthorized() bool {
+	return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+}
+
+// QuotaExceeded returns true if the account has run out of credits or quota. Unlike
+// a rate limit, waiting a few seconds does not help.
+func (e *StatusError) QuotaExceeded() bool {
+	if e.StatusCode == http.StatusPaymentRequired {
+		return true
+	}
+	body := strings.ToLower(e.Body)
+	return e.StatusCode == http.StatusTooManyRequests &&
+		(strings.Contains(body, "quota") || strings.Contains(body, "credit") || strings.Contains(body, "billing"))
+}
+
+// TooLarge returns true if the request was rejected because the bundle does not fit,
+// either in the request size limit of the server or in the context window of the model.
+func (e *StatusError) TooLarge() bool {
+	if e.StatusCode == http.StatusRequestEntityTooLarge {
+		return true
+	}
+	body := strings.ToLower(e.Body)
+	return e.StatusCode == http.StatusBadRequest &&
+		(strings.Contains(body, "context_length_exceeded") || strings.Contains(body, "prompt is too long") ||
+			strings.Contains(body, "maximum context length"))
+}
+
+// Temporary returns true if the request may succeed when it is retried.
+func (e *StatusError) Temporary() bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests:
+		return !e.QuotaExceeded()
+	case http.StatusRequestTimeout, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// IsTemporary returns true if the error is a failure that may be resolved by retrying
+// later, such as a rate limit, an overloaded server, a network error or a broken stream.
+func IsTemporary(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Temporary()
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, ErrStreamInterrupted) {
+		return true
+	}
+	// Every *url.Error is a net.Error, so only network failures that are known to pass
+	// are transient. Invalid URLs and TLS or certificate failures are not.
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTemporary || dnsErr.IsTimeout
+	}
+	return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// httpClient sends JSON requests to a provider using the configured HTTP options.
+type httpClient struct {
+	client      *http.Client
+	headers     map[string]string
+	maxAttempts int
+	retryDelay  time.Duration
+	onRetry     func(err error, delay time.Duration, attempt int)
+}
+
+// Creates an HTTP client with the proxy, certificates and timeout of the options.
+func newHTTPClient(opts HTTPOptions) (*httpClient, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.Proxy != "" {
+		proxyURL, err := url.Parse(opts.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy %q: %w", opts.Proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if opts.CACert != "" {
+		pem, err := os.ReadFile(opts.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", opts.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if opts.ClientCert != "" || opts.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(opts.ClientCert, opts.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	client := &httpClient{
+		client:      &http.Client{Transport: transport, Timeout: opts.Timeout},
+		headers:     opts.Headers,
+		maxAttempts: opts.MaxAttempts,
+		retryDelay:  opts.RetryDelay,
+		onRetry:     opts.OnRetry,
+	}
+	if client.maxAttempts <= 0 {
+		client.maxAttempts = defaultMaxAttempts
+	}
+	if client.retryDelay <= 0 {
+		client.retryDelay = defaultRetryDelay
+	}
+	return client, nil
+}
+
+// Prepares a POST request that sends the input as JSON with the given headers, followed
+// by the headers of the options so they can override the defaults of a provider.
+func (c *httpClient) prepareRequest(ctx context.Context, url string, input any, headers map[string]string) (*http.Request, error) {
+	jsonData, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	// Set the request header to specify JSON format
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	for key, value := range c.headers {
+		req.Header.Set(key, value)
+	}
+	return req, nil
+}
+
+// Sends the request and returns the response if its status code is 200. Transient
+// failures are retried with jittered exponential backoff, honoring Retry-After.
+func (c *httpClient) sendRequest(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, retryAfter, err := c.sendOnce(req)
+		if err == nil {
+			return resp, nil
+		}
+		if attempt >= c.maxAttempts || !IsTemporary(err) {
+			return nil, err
+		}
+
+		delay := Backoff(c.retryDelay, attempt)
+		if retryAfter > 0 {
+			delay = min(retryAfter, maxRetryAfter)
+		}
+		if c.onRetry != nil {
+			c.onRetry(err, delay, attempt+1)
+		}
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+
+		// The body has been consumed by the previous attempt.
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+	}
+}
+
+// Sends the request once and returns the response if its status code is 200, or the
+// error together with the delay the server asked for with Retry-After.
+func (c *httpClient) sendOnce(req *http.Request) (*http.Response, time.Duration, error) {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error sending request to %s: %w", req.URL, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		statusErr := &StatusError{URL: req.URL.String(), StatusCode: resp.StatusCode, Body: string(body)}
+		return nil, parseRetryAfter(resp.Header.Get("Retry-After")), statusErr
+	}
+	return resp, 0, nil
+}
+
+// Backoff returns the delay before the given retry: the base delay doubled for every
+// previous attempt, capped at 30 seconds, and with random jitter so that clients do not
+// retry in lockstep.
+func Backoff(base time.Duration, attempt int) time.Duration {
+	// Stop doubling at the cap, so that many attempts do not overflow the delay.
+	delay := min(max(base, 0), maxRetryDelay)
+	for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+		delay = min(delay*2, maxRetryDelay)
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// Parses a Retry-After header, which is either a number of seconds or an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
+
+// Sends the input as JSON to the url asking for a streamed response, and returns the
+// response whose body the caller has to close.
+func (c *httpClient) postStream(ctx context.Context, url string, input any, headers map[string]string) (*http.Response, error) {
+	req, err := c.prepareRequest(ctx, url, input, headers)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream, application/x-ndjson, application/json")
+	return c.sendRequest(req)
+}
+
+// Sends the input as JSON to the url and decodes the JSON response into output.
+func (c *httpClient) postJSON(ctx context.Context, url string, input any, headers map[string]string, output any) error {
+	req, err := c.prepareRequest(ctx, url, input, headers)
+	if err != nil {
+		return fmt.Errorf("error preparing request: %w", err)
+	}
+	resp, err := c.sendRequest(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(output); err != nil {
+		return fmt.Errorf("error decoding response of %s: %w", url, err)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+// Contains the focus presets and the code to add instructions to the review prompt.
+package review
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Instructions of the focus presets, which make a review concentrate on one aspect.
+var focusPresets = map[string]string{
+	"security": `Focus on security: injection, authentication and authorization flaws, unsafe handling of
+secrets and user input, insecure defaults, missing validation and vulnerable use of dependencies.`,
+	"performance": `Focus on performance: unnecessary allocations and copies, inefficient algorithms and queries,
+blocking calls, missing caching or batching, and resources that are not released.`,
+	"tests": `Focus on tests: untested code paths and edge cases, tests that do not assert what they claim,
+flaky or slow tests, and code that is hard to test.`,
+	"readability": `Focus on readability: unclear names, long or deeply nested functions, duplicated code,
+missing or misleading comments, and inconsistencies with the conventions of the codebase.`,
+}
+
+// FocusNames returns the names of the focus presets in alphabetical order.
+func FocusNames() []string {
+	names := make([]string, 0, len(focusPresets))
+	for name := range focusPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Focus returns the instructions of a focus preset.
+func Focus(name string) (string, error) {
+	instructions, ok := focusPresets[strings.ToLower(name)]
+	if !ok {
+		return "", fmt.Errorf("unknown focus %q, available focus presets: %s", name, strings.Join(FocusNames(), ", "))
+	}
+	return instructions, nil
+}
+
+// Returns the system prompt of reviews with the instructions of the team added to it.
+func reviewSystemPrompt(instructions string) string {
+	instructions = strings.TrimSpace(instructions)
+	if instructions == "" {
+		return reviewPrompt
+	}
+	return reviewPrompt + `
+
+The team that owns the code gave the following instructions. Check the code against them and
+report violations like any other issue:
+
+` + instructions
+}
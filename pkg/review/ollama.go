@@ -0,0 +1,124 @@
+// Contains the provider for a local Ollama server.
+package review
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	ollamaBaseURL = "http://localhost:11434"
+	ollamaModel   = "llama3.1"
+)
+
+type ollamaRequest struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+	Stream   bool      `json:"stream"`
+}
+
+type ollamaResponse struct {
+	Message Message `json:"message"`
+	Done    bool    `json:"done"`
+	Error   string  `json:"error"`
+}
+
+// ollamaProvider sends code to the chat endpoint of a local Ollama server. A llama.cpp
+// server can be used through the openai provider, as it is OpenAI compatible.
+type ollamaProvider struct {
+	client  *httpClient
+	baseURL string
+	model   string
+	apiKey  string
+	// System prompt of reviews, including the instructions of the options.
+	prompt string
+}
+
+func newOllamaProvider(opts Options, client *httpClient) *ollamaProvider {
+	return &ollamaProvider{
+		client:  client,
+		baseURL: baseURLOr(opts, ollamaBaseURL),
+		model:   modelOr(opts, ollamaModel),
+		apiKey:  opts.APIKey,
+		prompt:  reviewSystemPrompt(opts.Instructions),
+	}
+}
+
+func (p *ollamaProvider) Name() string { return "ollama" }
+
+func (p *ollamaProvider) Destination() string {
+	return p.baseURL + "/api/chat (" + p.model + ")"
+}
+
+func (p *ollamaProvider) Review(ctx context.Context, codeToReview string) (string, error) {
+	return p.complete(ctx, p.prompt, []Message{{Role: "user", Content: codeToReview}})
+}
+
+func (p *ollamaProvider) ReviewStream(ctx context.Context, codeToReview string, w io.Writer) (string, error) {
+	return p.completeStream(ctx, p.prompt, []Message{{Role: "user", Content: codeToReview}}, w)
+}
+
+func (p *ollamaProvider) Chat(ctx context.Context, system string, messages []Message, w io.Writer) (string, error) {
+	if w == nil {
+		return p.complete(ctx, system, messages)
+	}
+	return p.completeStream(ctx, system, messages, w)
+}
+
+// Returns the request for the conversation, preceded by the system prompt, and its headers.
+func (p *ollamaProvider) request(system string, messages []Message, stream bool) (ollamaRequest, map[string]string) {
+	input := ollamaRequest{
+		Model:    p.model,
+		Messages: append([]Message{{Role: "system", Content: system}}, messages...),
+		Stream:   stream,
+	}
+	headers := map[string]string{}
+	// Ollama does not need an API key, but proxies in front of it might.
+	if p.apiKey != "" {
+		headers["Authorization"] = "Bearer " + p.apiKey
+	}
+	return input, headers
+}
+
+// Sends the conversation, preceded by the system prompt, and returns the reply of the model.
+func (p *ollamaProvider) complete(ctx context.Context, system string, messages []Message) (string, error) {
+	input, headers := p.request(system, messages, false)
+	var output ollamaResponse
+	if err := p.client.postJSON(ctx, p.baseURL+"/api/chat", input, headers, &output); err != nil {
+		return "", err
+	}
+	if output.Error != "" {
+		return "", fmt.Errorf("%s returned an error: %s", p.baseURL, output.Error)
+	}
+	return output.Message.Content, nil
+}
+
+// Sends the conversation, preceded by the system prompt, and writes the reply of the model
+// to w as it is streamed back as newline delimited JSON.
+func (p *ollamaProvider) completeStream(ctx context.Context, system string, messages []Message, w io.Writer) (string, error) {
+	input, headers := p.request(system, messages, true)
+	resp, err := p.client.postStream(ctx, p.baseURL+"/api/chat", input, headers)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var reply strings.Builder
+	err = readLines(resp.Body, func(line string) (bool, error) {
+		var chunk ollamaResponse
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			return false, fmt.Errorf("error decoding stream of %s: %w", p.baseURL, err)
+		}
+		if chunk.Error != "" {
+			return false, fmt.Errorf("%w: %s", ErrStreamInterrupted, chunk.Error)
+		}
+		if err := writeChunk(w, &reply, chunk.Message.Content); err != nil {
+			return false, err
+		}
+		return chunk.Done, nil
+	})
+	return reply.String(), err
+}
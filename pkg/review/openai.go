@@ -0,0 +1,133 @@
+// Contains the provider for OpenAI compatible chat completions endpoints.
+package review
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	openAIBaseURL = "https://api.openai.com/v1"
+	openAIModel   = "gpt-4o"
+)
+
+type openAIRequest struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+	Stream   bool      `json:"stream,omitempty"`
+}
+
+type openAIResponse struct {
+	Choices []struct {
+		Message Message `json:"message"`
+	} `json:"choices"`
+}
+
+type openAIStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+}
+
+// openAIProvider sends code to an OpenAI compatible chat completions endpoint, which
+// includes OpenAI itself, Azure OpenAI deployments, vLLM and the llama.cpp server.
+type openAIProvider struct {
+	client  *httpClient
+	baseURL string
+	model   string
+	apiKey  string
+	// System prompt of reviews, including the instructions of the options.
+	prompt string
+}
+
+func newOpenAIProvider(opts Options, client *httpClient) *openAIProvider {
+	return &openAIProvider{
+		client:  client,
+		baseURL: baseURLOr(opts, openAIBaseURL),
+		model:   modelOr(opts, openAIModel),
+		apiKey:  opts.APIKey,
+		prompt:  reviewSystemPrompt(opts.Instructions),
+	}
+}
+
+func (p *openAIProvider) Name() string { return "openai" }
+
+func (p *openAIProvider) Destination() string {
+	return p.baseURL + "/chat/completions (" + p.model + ")"
+}
+
+func (p *openAIProvider) Review(ctx context.Context, codeToReview string) (string, error) {
+	return p.complete(ctx, p.prompt, []Message{{Role: "user", Content: codeToReview}})
+}
+
+func (p *openAIProvider) ReviewStream(ctx context.Context, codeToReview string, w io.Writer) (string, error) {
+	return p.completeStream(ctx, p.prompt, []Message{{Role: "user", Content: codeToReview}}, w)
+}
+
+func (p *openAIProvider) Chat(ctx context.Context, system string, messages []Message, w io.Writer) (string, error) {
+	if w == nil {
+		return p.complete(ctx, system, messages)
+	}
+	return p.completeStream(ctx, system, messages, w)
+}
+
+// Returns the request for the conversation, preceded by the system prompt, and its headers.
+func (p *openAIProvider) request(system string, messages []Message, stream bool) (openAIRequest, map[string]string) {
+	input := openAIRequest{
+		Model:    p.model,
+		Messages: append([]Message{{Role: "system", Content: system}}, messages...),
+		Stream:   stream,
+	}
+	headers := map[string]string{}
+	if p.apiKey != "" {
+		headers["Authorization"] = "Bearer " + p.apiKey
+	}
+	return input, headers
+}
+
+// Sends the conversation, preceded by the system prompt, and returns the reply of the model.
+func (p *openAIProvider) complete(ctx context.Context, system string, messages []Message) (string, error) {
+	input, headers := p.request(system, messages, false)
+	var output openAIResponse
+	if err := p.client.postJSON(ctx, p.baseURL+"/chat/completions", input, headers, &output); err != nil {
+		return "", err
+	}
+	if len(output.Choices) == 0 {
+		return "", fmt.Errorf("%s returned no choices", p.baseURL)
+	}
+	return output.Choices[0].Message.Content, nil
+}
+
+// Sends the conversation, preceded by the system prompt, and writes the reply of the model
+// to w as it is streamed back.
+func (p *openAIProvider) completeStream(ctx context.Context, system string, messages []Message, w io.Writer) (string, error) {
+	input, headers := p.request(system, messages, true)
+	resp, err := p.client.postStream(ctx, p.baseURL+"/chat/completions", input, headers)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var reply strings.Builder
+	err = readEvents(resp.Body, func(_ string, data string) (bool, error) {
+		if data == "[DONE]" {
+			return true, nil
+		}
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return false, fmt.Errorf("error decoding stream of %s: %w", p.baseURL, err)
+		}
+		for _, choice := range chunk.Choices {
+			if err := writeChunk(w, &reply, choice.Delta.Content); err != nil {
+				return false, err
+			}
+		}
+		return false, nil
+	})
+	return reply.String(), err
+}
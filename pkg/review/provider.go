@@ -0,0 +1,112 @@
+// Contains the interface that review providers implement and the code to select one.
+package review
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Provider sends code to a review service or language model and returns the review.
+type Provider interface {
+	// Name returns the name under which the provider is selected in the config.
+	Name() string
+	// Destination returns where the code is sent, as shown to the user before uploading.
+	Destination() string
+	// Review returns a markdown review of the given bundle.
+	Review(ctx context.Context, codeToReview string) (string, error)
+}
+
+// ChatProvider is implemented by providers that talk to a chat model, which can be given
+// other instructions than reviewing a bundle.
+type ChatProvider interface {
+	Provider
+	// Chat sends the conversation, preceded by the system prompt, and returns the reply of
+	// the model. If w is not nil, the reply is written to it while it is streamed.
+	Chat(ctx context.Context, system string, messages []Message, w io.Writer) (string, error)
+}
+
+// Message is a single message of a conversation with a chat model.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// The instructions chat models receive when reviewing a bundle.
+const reviewPrompt = `You are an experienced software engineer reviewing a codebase.
+The user sends a bundled project: the directory structure followed by the content of every file,
+each introduced by "File:" and its path.
+
+Write a code review in markdown. Start with a short summary of the project, then list concrete
+issues grouped by file: bugs, security problems, performance problems and maintainability concerns.
+Refer to files by their path, explain why something is a problem and suggest how to fix it.
+End with the most important improvements to make first.
+
+` + findingsPrompt
+
+// NewProvider returns the provider selected by the options.
+func NewProvider(opts Options) (Provider, error) {
+	client, err := newHTTPClient(opts.HTTP)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(opts.Provider) {
+	case "", "crev":
+		return newCrevProvider(opts, client), nil
+	case "openai":
+		return newOpenAIProvider(opts, client), nil
+	case "anthropic":
+		return newAnthropicProvider(opts, client), nil
+	case "ollama":
+		return newOllamaProvider(opts, client), nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q, available providers: crev, openai, anthropic, ollama", opts.Provider)
+	}
+}
+
+// DefaultAPIKeyEnv returns the environment variable that holds the API key of a provider
+// when the config does not specify one with api_key_env.
+func DefaultAPIKeyEnv(provider string) string {
+	switch strings.ToLower(provider) {
+	case "", "crev":
+		return "CREV_API_KEY"
+	case "openai":
+		return "OPENAI_API_KEY"
+	case "anthropic":
+		return "ANTHROPIC_API_KEY"
+	default:
+		return ""
+	}
+}
+
+// DefaultModel returns the model a provider uses when the config does not specify one.
+// It returns an empty string for the crev service, which selects the model itself.
+func DefaultModel(provider string) string {
+	switch strings.ToLower(provider) {
+	case "openai":
+		return openAIModel
+	case "anthropic":
+		return anthropicModel
+	case "ollama":
+		return ollamaModel
+	default:
+		return ""
+	}
+}
+
+// Returns the base URL of the config without a trailing slash, or the fallback if none is set.
+func baseURLOr(opts Options, fallback string) string {
+	if opts.BaseURL == "" {
+		return fallback
+	}
+	return strings.TrimSuffix(opts.BaseURL, "/")
+}
+
+// Returns the model of the config, or the fallback if none is set.
+func modelOr(opts Options, fallback string) string {
+	if opts.Model == "" {
+		return fallback
+	}
+	return opts.Model
+}
@@ -0,0 +1,163 @@
+// Package review sends crev bundles to a review service or language model and returns
+// the review. It is the library behind the crev review command:
+//
+//	bundle, err := os.Open("crev-project.txt")
+//	if err != nil {
+//		return err
+//	}
+//	defer bundle.Close()
+//	result, err := review.Review(ctx, bundle, review.Options{
+//		Provider: "anthropic",
+//		APIKey:   os.Getenv("ANTHROPIC_API_KEY"),
+//	})
+//	if errors.Is(err, review.ErrQuota) {
+//		// ...
+//	}
+//
+// Errors never terminate the program, and writing the review is left to the caller.
+package review
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/vossenwout/crev/internal/tokens"
+)
+
+// Options selects and configures a provider and how the review is requested.
+type Options struct {
+	// Provider is one of "crev" (default), "openai", "anthropic" or "ollama".
+	Provider string
+	// Model is the model to use. Every provider except crev has a default.
+	Model string
+	// BaseURL overrides the default endpoint of the provider.
+	BaseURL string
+	// APIKey authenticates the requests. Ollama does not need one.
+	APIKey string
+	// HTTP configures how requests are sent.
+	HTTP HTTPOptions
+	// Instructions are sent with every review request, so the review checks conventions
+	// of the team, ex. "All HTTP handlers must take a context". See also Focus.
+	Instructions string
+	// MaxBundleSize rejects bundles larger than this number of bytes with ErrTooLarge
+	// before anything is sent. Zero means no limit.
+	MaxBundleSize int64
+	// Stream, if set and supported by the provider, receives the review while it is written.
+	// The block in which the reviewer lists its findings is not written to it.
+	Stream io.Writer
+	// LineCounts is the number of lines of every bundled file, used to validate findings.
+	// It defaults to the line counts of the file sections of the bundle, which only need
+	// to be overridden when the sections are not complete files, as in a diff.
+	LineCounts map[string]int
+	// ChunkTokens splits bundles of more than this number of estimated tokens into chunks
+	// that are reviewed separately, after which the reviews are combined into one. Zero
+	// always reviews the bundle at once.
+	ChunkTokens int
+	// Concurrency is the number of chunks that are reviewed at the same time, defaults to
+	// DefaultConcurrency.
+	Concurrency int
+	// OnChunk, if set, is called when a chunk has been reviewed.
+	OnChunk func(done int, total int)
+}
+
+// Result is a completed review.
+type Result struct {
+	// Review is the review in markdown, without the block that lists the findings.
+	Review string
+	// Findings are the findings of the review that match the bundled files.
+	Findings []Finding
+	// InvalidFindings are the findings that do not match the bundled files.
+	InvalidFindings []InvalidFinding
+	// FindingsError is set when the findings could not be parsed. The review is then
+	// kept as it was written.
+	FindingsError error
+	// FindingsMissing is set when the review, or a chunk of it, did not list its findings
+	// in a crev-findings block, so its findings are unknown rather than none.
+	FindingsMissing bool
+	// Provider is the name of the provider that wrote the review.
+	Provider string
+	// Duration is how long the review took.
+	Duration time.Duration
+	// Chunks is the number of chunks the bundle was split into, or 0 if it was reviewed at once.
+	Chunks int
+}
+
+// Review reads the bundle and sends it for review to the provider selected by the
+// options. The context can be used to cancel the review or to set a deadline.
+//
+// The findings the reviewer lists are parsed and validated against the bundle. If a
+// streamed review is interrupted, the partial review is returned together with an
+// error wrapping ErrStreamInterrupted.
+func Review(ctx context.Context, bundle io.Reader, opts Options) (*Result, error) {
+	provider, err := NewProvider(opts)
+	if err != nil {
+		return nil, err
+	}
+	return ReviewWith(ctx, provider, bundle, opts)
+}
+
+// ReviewWith is like Review but uses the given provider, which may be a custom
+// implementation. The Provider, Model, BaseURL, APIKey and HTTP options are not used.
+func ReviewWith(ctx context.Context, provider Provider, bundle io.Reader, opts Options) (*Result, error) {
+	code, err := readBundle(bundle, opts.MaxBundleSize)
+	if err != nil {
+		return nil, err
+	}
+
+	lineCounts := opts.LineCounts
+	if lineCounts == nil {
+		lineCounts = LineCounts(code)
+	}
+
+	start := time.Now()
+	if _, maxTokens := tokens.Range(code); opts.ChunkTokens > 0 && maxTokens > opts.ChunkTokens {
+		if chunks := SplitBundle(code, opts.ChunkTokens); len(chunks) > 1 {
+			result, err := reviewChunks(ctx, provider, chunks, lineCounts, opts)
+			if result != nil {
+				result.Duration = time.Since(start)
+			}
+			return result, err
+		}
+	}
+
+	var review string
+	if streamer, ok := provider.(StreamingProvider); ok && opts.Stream != nil {
+		stream := &hideFindingsWriter{w: opts.Stream}
+		review, err = streamer.ReviewStream(ctx, code, stream)
+		if flushErr := stream.Flush(); err == nil {
+			err = flushErr
+		}
+	} else {
+		review, err = provider.Review(ctx, code)
+	}
+	if err != nil && review == "" {
+		return nil, err
+	}
+	result := &Result{Review: review, Provider: provider.Name(), Duration: time.Since(start), FindingsMissing: !listsFindings(review)}
+
+	markdown, findings, findingsErr := ParseFindings(review)
+	if findingsErr != nil {
+		result.FindingsError = findingsErr
+		return result, err
+	}
+	result.Review = markdown
+	result.Findings, result.InvalidFindings = ValidateFindings(findings, lineCounts)
+	return result, err
+}
+
+// Reads the bundle, failing with ErrTooLarge if it exceeds the maximum size.
+func readBundle(bundle io.Reader, maxSize int64) (string, error) {
+	if maxSize > 0 {
+		bundle = io.LimitReader(bundle, maxSize+1)
+	}
+	code, err := io.ReadAll(bundle)
+	if err != nil {
+		return "", fmt.Errorf("error reading bundle: %w", err)
+	}
+	if maxSize > 0 && int64(len(code)) > maxSize {
+		return "", fmt.Errorf("%w: the bundle exceeds the maximum size of %d bytes", ErrTooLarge, maxSize)
+	}
+	return string(code), nil
+}
@@ -0,0 +1,96 @@
+// Contains code to read streamed responses of review providers.
+package review
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// StreamingProvider is implemented by providers that can stream the review while the
+// model generates it.
+type StreamingProvider interface {
+	Provider
+	// ReviewStream writes the review to w as it arrives and returns the complete review.
+	// If the stream breaks off, the part received so far is returned with an error
+	// wrapping ErrStreamInterrupted.
+	ReviewStream(ctx context.Context, codeToReview string, w io.Writer) (string, error)
+}
+
+// ErrStreamInterrupted is returned when a streamed review ends before it is complete.
+var ErrStreamInterrupted = errors.New("review stream interrupted")
+
+// Maximum size of a single line in a stream, large enough for big chunks of text.
+const maxStreamLineSize = 1 << 20
+
+// Reads server-sent events from r and calls onEvent with the name and data of every
+// event. Reading stops when onEvent returns done or an error.
+func readEvents(r io.Reader, onEvent func(event string, data string) (done bool, err error)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxStreamLineSize)
+	event := ""
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if len(data) == 0 {
+				event = ""
+				continue
+			}
+			done, err := onEvent(event, strings.Join(data, "\n"))
+			if err != nil || done {
+				return err
+			}
+			event, data = "", nil
+		case strings.HasPrefix(line, ":"):
+			// Comments are used as keep-alives.
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%w: %w", ErrStreamInterrupted, err)
+	}
+	// Servers may omit the blank line after the last event.
+	if len(data) > 0 {
+		done, err := onEvent(event, strings.Join(data, "\n"))
+		if err != nil || done {
+			return err
+		}
+	}
+	return fmt.Errorf("%w: the stream ended before the review was complete", ErrStreamInterrupted)
+}
+
+// Reads newline delimited JSON from r and calls onLine for every line. Reading stops
+// when onLine returns done or an error.
+func readLines(r io.Reader, onLine func(line string) (done bool, err error)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxStreamLineSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		done, err := onLine(line)
+		if err != nil || done {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%w: %w", ErrStreamInterrupted, err)
+	}
+	return fmt.Errorf("%w: the stream ended before the review was complete", ErrStreamInterrupted)
+}
+
+// Writes a chunk of the review to w and appends it to the complete review.
+func writeChunk(w io.Writer, review *strings.Builder, chunk string) error {
+	review.WriteString(chunk)
+	_, err := io.WriteString(w, chunk)
+	return err
+}
@@ -0,0 +1,36 @@
+package ask_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vossenwout/crev/internal/ask"
+)
+
+// Tests that citations of bundled lines are returned sorted and without duplicates, and
+// citations of lines that do not exist are reported.
+func TestCitations(t *testing.T) {
+	lineCounts := map[string]int{"main.go": 10, "internal/auth/auth.go": 40}
+	answer := "Requests are authenticated in `internal/auth/auth.go:12-30`, called from main.go:4.\n" +
+		"See also main.go:4, main.go:20, internal/auth/auth.go:30-12 and localhost:8080."
+
+	citations, invalid := ask.Citations(answer, lineCounts)
+	var got []string
+	for _, citation := range citations {
+		got = append(got, citation.String())
+	}
+	expected := "internal/auth/auth.go:12-30, main.go:4"
+	if strings.Join(got, ", ") != expected {
+		t.Errorf("expected citations %s, got %s", expected, strings.Join(got, ", "))
+	}
+	if strings.Join(invalid, ", ") != "main.go:20, internal/auth/auth.go:30-12" {
+		t.Errorf("expected the citations of missing lines to be invalid, got %v", invalid)
+	}
+}
+
+// Tests that the prompt contains the bundle.
+func TestPrompt(t *testing.T) {
+	if !strings.HasSuffix(ask.Prompt("the bundle"), "the bundle") {
+		t.Errorf("expected the prompt to end with the bundle")
+	}
+}
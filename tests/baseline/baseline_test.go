@@ -0,0 +1,65 @@
+package baseline_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/vossenwout/crev/internal/baseline"
+	"github.com/vossenwout/crev/pkg/review"
+)
+
+const content = "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hello\")\n}\n"
+
+// Tests that fingerprints follow the code of a finding instead of its line numbers.
+func TestFingerprint(t *testing.T) {
+	finding := review.Finding{Path: "main.go", StartLine: 6, EndLine: 6, Category: "bug", Message: "prints hello"}
+	fingerprint := baseline.Fingerprint(finding, content)
+
+	moved := finding
+	moved.StartLine, moved.EndLine = 8, 8
+	moved.Message = "says hello"
+	if baseline.Fingerprint(moved, "// Copyright\n\n"+content) != fingerprint {
+		t.Error("expected the fingerprint to survive lines added above the finding")
+	}
+	if baseline.Fingerprint(finding, "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"bye\")\n}\n") == fingerprint {
+		t.Error("expected the fingerprint to change when the code of the finding changes")
+	}
+	other := finding
+	other.Category = "style"
+	if baseline.Fingerprint(other, content) == fingerprint {
+		t.Error("expected findings of other categories to have other fingerprints")
+	}
+
+	wholeFile := review.Finding{Path: "main.go", Category: "style", Message: "Missing  docs"}
+	if baseline.Fingerprint(wholeFile, content) != baseline.Fingerprint(review.Finding{Path: "main.go", Category: "style", Message: "missing docs"}, "") {
+		t.Error("expected findings without lines to be identified by their message")
+	}
+}
+
+// Tests that a saved baseline suppresses the findings it accepts.
+func TestBaseline(t *testing.T) {
+	accepted := review.Finding{Path: "main.go", StartLine: 6, Severity: review.SeverityLow, Message: "old", Fingerprint: "a"}
+	added := review.Finding{Path: "main.go", StartLine: 2, Severity: review.SeverityHigh, Message: "new", Fingerprint: "b"}
+	path := filepath.Join(t.TempDir(), "baseline.json")
+
+	err := baseline.New([]review.Finding{accepted, accepted}).Save(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	b, err := baseline.Load(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(b.Findings) != 1 || b.Findings[0].Message != "old" {
+		t.Errorf("expected one accepted finding, got %+v", b.Findings)
+	}
+
+	findings, suppressed := b.Filter([]review.Finding{accepted, added})
+	if suppressed != 1 || len(findings) != 1 || findings[0].Message != "new" {
+		t.Errorf("expected only the new finding, got %+v (%d suppressed)", findings, suppressed)
+	}
+
+	if _, err := baseline.Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected an error for a missing baseline")
+	}
+}
@@ -0,0 +1,87 @@
+package cache_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vossenwout/crev/internal/cache"
+)
+
+type entry struct {
+	Review string `json:"review"`
+}
+
+// Tests that keys depend on every part and on where the parts are split.
+func TestKey(t *testing.T) {
+	if cache.Key("code", "openai") != cache.Key("code", "openai") {
+		t.Errorf("expected the same parts to give the same key")
+	}
+	if cache.Key("code", "openai") == cache.Key("code", "anthropic") {
+		t.Errorf("expected other parts to give another key")
+	}
+	if cache.Key("ab", "c") == cache.Key("a", "bc") {
+		t.Errorf("expected moving text between parts to give another key")
+	}
+}
+
+// Tests that stored entries are returned and missing entries are reported.
+func TestPutGet(t *testing.T) {
+	c := cache.New(filepath.Join(t.TempDir(), "reviews"))
+	var got entry
+	if _, ok, err := c.Get("missing", &got); err != nil || ok {
+		t.Fatalf("expected no entry, got %v %v", ok, err)
+	}
+	if err := c.Put("key", entry{Review: "looks good"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	stored, ok, err := c.Get("key", &got)
+	if err != nil || !ok {
+		t.Fatalf("expected an entry, got %v %v", ok, err)
+	}
+	if got.Review != "looks good" {
+		t.Errorf("expected review %q, got %q", "looks good", got.Review)
+	}
+	if time.Since(stored) > time.Minute {
+		t.Errorf("expected the entry to be stored just now, got %v", stored)
+	}
+}
+
+// Tests that pruning removes only the entries older than the maximum age, or all of them.
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	c := cache.New(dir)
+	for _, key := range []string{"old", "new"} {
+		if err := c.Put(key, entry{Review: key}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "old.json"), old, old); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	removed, err := c.Prune(24 * time.Hour)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("expected 1 entry to be removed, got %d", removed)
+	}
+	var got entry
+	if _, ok, _ := c.Get("new", &got); !ok {
+		t.Errorf("expected the new entry to be kept")
+	}
+
+	removed, err = c.Prune(0)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("expected 1 entry to be removed, got %d", removed)
+	}
+	if removed, err := cache.New(filepath.Join(dir, "missing")).Prune(0); err != nil || removed != 0 {
+		t.Errorf("expected nothing to prune in a missing directory, got %d %v", removed, err)
+	}
+}
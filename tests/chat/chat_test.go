@@ -0,0 +1,105 @@
+package chat_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vossenwout/crev/internal/chat"
+	"github.com/vossenwout/crev/pkg/review"
+)
+
+// A chat provider that records what it is sent and answers with a fixed reply.
+type fakeProvider struct {
+	systems  []string
+	messages [][]review.Message
+	err      error
+}
+
+func (p *fakeProvider) Name() string        { return "fake" }
+func (p *fakeProvider) Destination() string { return "fake" }
+func (p *fakeProvider) Review(ctx context.Context, codeToReview string) (string, error) {
+	return "", errors.New("not used")
+}
+
+func (p *fakeProvider) Chat(ctx context.Context, system string, messages []review.Message, w io.Writer) (string, error) {
+	p.systems = append(p.systems, system)
+	p.messages = append(p.messages, messages)
+	if p.err != nil {
+		return "", p.err
+	}
+	answer := "answer " + messages[len(messages)-1].Content
+	if w != nil {
+		_, _ = io.WriteString(w, answer)
+	}
+	return answer, nil
+}
+
+// Tests that the conversation is kept, files are added to the context and the transcript is saved.
+func TestREPL(t *testing.T) {
+	provider := &fakeProvider{}
+	transcriptFile := filepath.Join(t.TempDir(), "transcript.md")
+	var out strings.Builder
+	repl := &chat.REPL{
+		Session: chat.NewSession(provider, "the bundle", "the review"),
+		In:      strings.NewReader("why?\n\n/add pkg\n/add missing\nand now?\n/exit\nignored\n"),
+		Out:     &out,
+		Load: func(path string) (string, string, error) {
+			if path == "missing" {
+				return "", "", errors.New("not found")
+			}
+			return "bundle of " + path, path + " (1 files)", nil
+		},
+		TranscriptFile: transcriptFile,
+	}
+	if err := repl.Run(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if len(provider.messages) != 2 {
+		t.Fatalf("expected 2 questions, got %d", len(provider.messages))
+	}
+	if len(provider.messages[1]) != 3 || provider.messages[1][1].Content != "answer why?" {
+		t.Errorf("expected the second question to be sent with the first answer, got %v", provider.messages[1])
+	}
+	for _, part := range []string{"the bundle", "the review"} {
+		if !strings.Contains(provider.systems[0], part) {
+			t.Errorf("expected %q in the system prompt, got %q", part, provider.systems[0])
+		}
+	}
+	if strings.Contains(provider.systems[0], "bundle of pkg") || !strings.Contains(provider.systems[1], "bundle of pkg") {
+		t.Errorf("expected the added files in the system prompt after they were added")
+	}
+	if !strings.Contains(out.String(), "Could not add missing") {
+		t.Errorf("expected an error for a file that cannot be added, got %q", out.String())
+	}
+
+	transcript, err := os.ReadFile(transcriptFile)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	for _, part := range []string{"## You\n\nwhy?", "## crev\n\nanswer why?", "*Added pkg (1 files) to the conversation.*", "answer and now?"} {
+		if !strings.Contains(string(transcript), part) {
+			t.Errorf("expected %q in the transcript, got %q", part, transcript)
+		}
+	}
+}
+
+// Tests that a question that fails is not kept in the conversation.
+func TestAskFailure(t *testing.T) {
+	provider := &fakeProvider{err: errors.New("overloaded")}
+	session := chat.NewSession(provider, "the bundle", "")
+	if _, err := session.Ask(context.Background(), "why?", nil); err == nil {
+		t.Fatalf("expected an error")
+	}
+	if len(session.Messages()) != 0 {
+		t.Errorf("expected no messages after a failed question, got %v", session.Messages())
+	}
+	if strings.Contains(session.System(), "# Review") {
+		t.Errorf("expected no review section without a review, got %q", session.System())
+	}
+}
@@ -0,0 +1,94 @@
+package cost_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/vossenwout/crev/internal/cost"
+)
+
+// Returns a bundle of files of the given size in separate directories.
+func createBundle(files int, size int) string {
+	var sb strings.Builder
+	sb.WriteString("Project Directory Structure:\n\n\n")
+	for i := range files {
+		fmt.Fprintf(&sb, "File: \ndir%d/main.go\nContent: \n%s\n\n", i, strings.Repeat("x", size))
+	}
+	return sb.String()
+}
+
+// Tests that configured prices take precedence over the default prices and unknown models have no price.
+func TestLookup(t *testing.T) {
+	price, ok := cost.Lookup("openai", "gpt-4o-2024-08-06", nil)
+	if !ok || price.Input != 2.50 || price.Output != 10 {
+		t.Errorf("expected the default price of gpt-4o, got %v %v", price, ok)
+	}
+	rules := []cost.PriceRule{{Provider: "OpenAI", Model: "gpt-4o", Input: 1, Output: 2}}
+	if price, ok := cost.Lookup("openai", "gpt-4o", rules); !ok || price.Input != 1 || price.Output != 2 {
+		t.Errorf("expected the configured price, got %v %v", price, ok)
+	}
+	if price, ok := cost.Lookup("ollama", "llama3.1", nil); !ok || price.Input != 0 {
+		t.Errorf("expected local models to be free, got %v %v", price, ok)
+	}
+	if _, ok := cost.Lookup("crev", "", nil); ok {
+		t.Errorf("expected the price of the crev service to be unknown")
+	}
+}
+
+// Tests that the tokens and price of a review are estimated.
+func TestNew(t *testing.T) {
+	bundle := createBundle(1, 30000)
+	estimate := cost.New(bundle, cost.Options{Provider: "openai", Model: "gpt-4o"})
+	if estimate.Requests != 1 {
+		t.Errorf("expected 1 request, got %d", estimate.Requests)
+	}
+	if estimate.MinInputTokens != len(bundle)/4 || estimate.MaxInputTokens != len(bundle)/3 {
+		t.Errorf("expected the token range of the bundle, got %d - %d", estimate.MinInputTokens, estimate.MaxInputTokens)
+	}
+	if estimate.OutputTokens != len(bundle)/3/10 {
+		t.Errorf("expected a tenth of the input as output, got %d", estimate.OutputTokens)
+	}
+	expected := (float64(estimate.MaxInputTokens)*2.50 + float64(estimate.OutputTokens)*10) / 1e6
+	if !estimate.KnownPrice || estimate.MaxCost() != expected {
+		t.Errorf("expected a maximum cost of %f, got %f", expected, estimate.MaxCost())
+	}
+	if !strings.Contains(estimate.String(), "$") {
+		t.Errorf("expected the cost in the estimate, got %s", estimate)
+	}
+}
+
+// Tests that chunked reviews need a request per chunk and, if the provider combines them,
+// one to combine them.
+func TestNewChunks(t *testing.T) {
+	bundle := createBundle(3, 3000)
+	single := cost.New(bundle, cost.Options{Provider: "openai", Model: "gpt-4o"})
+	chunked := cost.New(bundle, cost.Options{Provider: "openai", Model: "gpt-4o", ChunkTokens: 1100, Combine: true})
+	if chunked.Requests != 4 {
+		t.Errorf("expected 4 requests, got %d", chunked.Requests)
+	}
+	if chunked.MaxInputTokens <= single.MaxInputTokens || chunked.OutputTokens <= single.OutputTokens {
+		t.Errorf("expected chunked reviews to need more tokens, got %+v and %+v", chunked, single)
+	}
+
+	concatenated := cost.New(bundle, cost.Options{Provider: "crev", ChunkTokens: 1100})
+	if concatenated.Requests != 3 {
+		t.Errorf("expected 3 requests without combining, got %d", concatenated.Requests)
+	}
+	if concatenated.MaxInputTokens >= chunked.MaxInputTokens || concatenated.OutputTokens >= chunked.OutputTokens {
+		t.Errorf("expected concatenated reviews to need fewer tokens, got %+v and %+v", concatenated, chunked)
+	}
+}
+
+// Tests that the configured rules are not modified by a lookup.
+func TestLookupKeepsRules(t *testing.T) {
+	rules := make([]cost.PriceRule, 1, 2)
+	rules[0] = cost.PriceRule{Provider: "openai", Model: "gpt-4o", Input: 1, Output: 2}
+	backing := rules[:2]
+
+	cost.Lookup("openai", "gpt-4o-mini", rules)
+
+	if backing[1] != (cost.PriceRule{}) {
+		t.Errorf("expected the spare capacity of the rules to be untouched, got %+v", backing[1])
+	}
+}
@@ -0,0 +1,113 @@
+package fix_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vossenwout/crev/internal/fix"
+	"github.com/vossenwout/crev/pkg/review"
+)
+
+const answer = "Fixed the first issue:\n" +
+	"```diff\ndiff --git a/main.go b/main.go\nindex 123..456 100644\n--- main.go\n+++ main.go\n@@ -3,3 +3,9 @@\n func a() int {\n-\treturn 1\n+\treturn 2\n }\n```\n" +
+	"```diff\n--- a/../secrets.go\n+++ b/../secrets.go\n@@ -1 +1 @@\n-x\n+y\n```\n" +
+	"```diff\n--- /dev/null\n+++ b/new.go\n@@ -0,0 +1 @@\n+package new\n```\n" +
+	"```diff\n--- a/query.sql\n+++ b/query.sql\n@@ -1,2 +1,1 @@\n--- a comment\n SELECT 1;\n```\n"
+
+// Tests that patches are normalized and that patches of other files are rejected.
+func TestExtractPatches(t *testing.T) {
+	patches, errs := fix.ExtractPatches(answer, map[string]bool{"main.go": true, "query.sql": true})
+	if len(patches) != 2 {
+		t.Fatalf("expected 2 patches, got %d: %v", len(patches), patches)
+	}
+	expected := "--- a/main.go\n+++ b/main.go\n@@ -3,3 +3,9 @@\n func a() int {\n-\treturn 1\n+\treturn 2\n }\n"
+	if patches[0].Diff != expected {
+		t.Errorf("expected patch %q, got %q", expected, patches[0].Diff)
+	}
+	if !reflect.DeepEqual(patches[1].Paths, []string{"query.sql"}) || !strings.Contains(patches[1].Diff, "\n--- a comment\n") {
+		t.Errorf("expected a removed line starting with --- to be kept, got %q", patches[1].Diff)
+	}
+	if len(errs) != 2 {
+		t.Errorf("expected the patches of other and new files to be rejected, got %v", errs)
+	}
+}
+
+// Tests that findings are selected by numbers, ranges and "all".
+func TestParseSelection(t *testing.T) {
+	tests := []struct {
+		selection string
+		expected  []int
+	}{
+		{selection: "1", expected: []int{0}},
+		{selection: "3, 1 1", expected: []int{0, 2}},
+		{selection: "2-4", expected: []int{1, 2, 3}},
+		{selection: "all", expected: []int{0, 1, 2, 3}},
+	}
+	for _, tt := range tests {
+		indexes, err := fix.ParseSelection(tt.selection, 4)
+		if err != nil {
+			t.Errorf("expected no error for %q, got %v", tt.selection, err)
+			continue
+		}
+		if !reflect.DeepEqual(indexes, tt.expected) {
+			t.Errorf("expected %v for %q, got %v", tt.expected, tt.selection, indexes)
+		}
+	}
+	for _, selection := range []string{"", "0", "5", "3-2", "one"} {
+		if _, err := fix.ParseSelection(selection, 4); err == nil {
+			t.Errorf("expected an error for %q", selection)
+		}
+	}
+}
+
+// Tests that patches are checked and applied to the files in the directory.
+func TestCheckApply(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(path, []byte("package a\n\nfunc a() int {\n\treturn 1\n}\n"), 0644); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	patches, _ := fix.ExtractPatches(answer, map[string]bool{"main.go": true})
+	opts := fix.Options{Dir: dir}
+	if err := fix.Check(context.Background(), patches[0], opts); err != nil {
+		t.Fatalf("expected the patch to apply, got %v", err)
+	}
+	if err := fix.Apply(context.Background(), patches[0], opts); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !strings.Contains(string(content), "return 2") {
+		t.Errorf("expected the patch to be applied, got %q", content)
+	}
+	if err := fix.Check(context.Background(), patches[0], opts); err == nil {
+		t.Errorf("expected a patch that was already applied not to apply again")
+	}
+}
+
+// Tests that the request contains the findings and the files.
+func TestRequest(t *testing.T) {
+	finding := review.Finding{Path: "main.go", StartLine: 4, EndLine: 4, Severity: review.SeverityHigh, Category: "bug", Message: "Returns 1", Suggestion: "Return 2"}
+	_, message := fix.Request([]review.Finding{finding}, map[string]string{"main.go": "package a\n"})
+	for _, part := range []string{"1. main.go:4: high [bug] Returns 1", "Suggestion: Return 2", "## main.go\n\n```\npackage a\n```"} {
+		if !strings.Contains(message, part) {
+			t.Errorf("expected %q in the request, got %q", part, message)
+		}
+	}
+}
+
+// Tests that removed and added lines are colored.
+func TestColorize(t *testing.T) {
+	colored := fix.Colorize("--- a/main.go\n+++ b/main.go\n@@ -1 +1 @@\n-x\n+y\n z\n")
+	for _, part := range []string{"\033[31m-x\033[0m\n", "\033[32m+y\033[0m\n", "\n z\n"} {
+		if !strings.Contains(colored, part) {
+			t.Errorf("expected %q in the colored patch, got %q", part, colored)
+		}
+	}
+}
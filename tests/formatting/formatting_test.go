@@ -58,3 +58,39 @@ func TestCreateProjectString(t *testing.T) {
 		t.Errorf("expected \n%s\n, got \n%s\n", expected, result)
 	}
 }
+
+func TestParseProjectString(t *testing.T) {
+	fileContentMap := map[string]string{
+		"cmd/ai-code-review/main.go": "package main\n",
+		"go.mod":                     "go mod",
+		"docs/format.md":             "File: \nis how sections start\n",
+	}
+	projectString := formatting.CreateProjectString("tree\n", fileContentMap)
+
+	result := formatting.ParseProjectString(projectString)
+
+	if len(result) != len(fileContentMap) {
+		t.Fatalf("expected %d files, got %d", len(fileContentMap), len(result))
+	}
+	for path, content := range fileContentMap {
+		if result[path] != content {
+			t.Errorf("expected content %q for %s, got %q", content, path, result[path])
+		}
+	}
+}
+
+func TestNumberLines(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected string
+	}{
+		{content: "a\nb\n", expected: "1 | a\n2 | b\n"},
+		{content: "a\nb", expected: "1 | a\n2 | b"},
+		{content: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n", expected: " 1 | 1\n 2 | 2\n 3 | 3\n 4 | 4\n 5 | 5\n 6 | 6\n 7 | 7\n 8 | 8\n 9 | 9\n10 | 10\n"},
+	}
+	for _, tt := range tests {
+		if result := formatting.NumberLines(tt.content); result != tt.expected {
+			t.Errorf("expected %q, got %q", tt.expected, result)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package history_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vossenwout/crev/internal/history"
+	"github.com/vossenwout/crev/pkg/review"
+)
+
+// Tests that saved runs are listed oldest first and can be found by ID, prefix or "latest".
+func TestSaveListGet(t *testing.T) {
+	h := history.New(t.TempDir())
+	if runs, err := h.List(); err != nil || len(runs) != 0 {
+		t.Fatalf("expected an empty history, got %v %v", runs, err)
+	}
+	start := time.Date(2024, 6, 1, 10, 15, 0, 0, time.UTC)
+	for _, at := range []time.Time{start.Add(time.Hour), start, start} {
+		if err := h.Save(&history.Run{Time: at, Provider: "openai", BundleHash: history.BundleHash("code")}); err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	}
+
+	runs, err := h.List()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	expected := []string{"20240601-101500", "20240601-101500-2", "20240601-111500"}
+	if len(runs) != len(expected) {
+		t.Fatalf("expected %d runs, got %d", len(expected), len(runs))
+	}
+	for i, id := range expected {
+		if runs[i].ID != id {
+			t.Errorf("expected run %d to be %s, got %s", i, id, runs[i].ID)
+		}
+	}
+
+	tests := []struct {
+		ref string
+		id  string
+	}{
+		{ref: "latest", id: "20240601-111500"},
+		{ref: "20240601-101500", id: "20240601-101500"},
+		{ref: "20240601-11", id: "20240601-111500"},
+	}
+	for _, tt := range tests {
+		run, err := h.Get(tt.ref)
+		if err != nil {
+			t.Errorf("expected no error for %s, got %v", tt.ref, err)
+			continue
+		}
+		if run.ID != tt.id {
+			t.Errorf("expected %s to refer to %s, got %s", tt.ref, tt.id, run.ID)
+		}
+	}
+	for _, ref := range []string{"20240601", "20230101"} {
+		if _, err := h.Get(ref); err == nil {
+			t.Errorf("expected an error for %s", ref)
+		}
+	}
+}
+
+// Tests that findings are sorted into fixed, persisted and introduced by their fingerprints.
+func TestCompare(t *testing.T) {
+	fixed := review.Finding{Path: "a.go", StartLine: 1, Category: "bug", Message: "fixed", Fingerprint: "1"}
+	persisted := review.Finding{Path: "a.go", StartLine: 5, Category: "bug", Message: "persisted", Fingerprint: "2"}
+	moved := persisted
+	moved.StartLine = 9
+	introduced := review.Finding{Path: "b.go", StartLine: 2, Category: "style", Message: "introduced", Fingerprint: "3"}
+
+	a := &history.Run{Findings: []review.Finding{fixed, persisted, persisted}}
+	b := &history.Run{Findings: []review.Finding{moved, introduced}}
+	comparison := history.Compare(a, b)
+	if len(comparison.Fixed) != 2 || comparison.Fixed[0].Message != "fixed" || comparison.Fixed[1].Message != "persisted" {
+		t.Errorf("expected the fixed finding and one of the duplicates to be fixed, got %v", comparison.Fixed)
+	}
+	if len(comparison.Persisted) != 1 || comparison.Persisted[0].StartLine != 9 {
+		t.Errorf("expected the moved finding to persist, got %v", comparison.Persisted)
+	}
+	if len(comparison.Introduced) != 1 || comparison.Introduced[0].Message != "introduced" {
+		t.Errorf("expected the new finding to be introduced, got %v", comparison.Introduced)
+	}
+}
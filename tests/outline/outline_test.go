@@ -0,0 +1,213 @@
+package outline_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vossenwout/crev/internal/outline"
+)
+
+const goSource = `// Package shapes contains shapes.
+package shapes
+
+import "math"
+
+// Shape is anything with an area.
+type Shape interface {
+	Area() float64
+}
+
+// Circle is a round shape.
+type Circle struct {
+	// Radius of the circle.
+	Radius float64
+}
+
+// Area returns the area of the circle.
+func (c Circle) Area() float64 {
+	// body comment
+	return math.Pi * c.Radius * c.Radius
+}
+
+var unit = Circle{Radius: 1}
+`
+
+// Tests that Go files are reduced to their declarations without function bodies.
+func TestContentMapOutlinesGoFiles(t *testing.T) {
+	fileContentMap := map[string]string{
+		"shapes/circle.go": goSource,
+		"readme.txt":       "plain text",
+	}
+
+	result := outline.ContentMap(fileContentMap)
+
+	goOutline := result["shapes/circle.go"]
+	for _, expected := range []string{
+		"// Package shapes contains shapes.\npackage shapes",
+		`import "math"`,
+		"// Shape is anything with an area.\ntype Shape interface {\n\tArea() float64\n}",
+		"\t// Radius of the circle.\n\tRadius float64",
+		"// Area returns the area of the circle.\nfunc (c Circle) Area() float64\n",
+	} {
+		if !strings.Contains(goOutline, expected) {
+			t.Errorf("expected outline to contain %q, got \n%s\n", expected, goOutline)
+		}
+	}
+	for _, elided := range []string{"body comment", "math.Pi", "var unit"} {
+		if strings.Contains(goOutline, elided) {
+			t.Errorf("expected outline not to contain %q, got \n%s\n", elided, goOutline)
+		}
+	}
+
+	if result["readme.txt"] != "plain text" {
+		t.Errorf("expected unsupported file to keep its content, got %s", result["readme.txt"])
+	}
+}
+
+// Tests that files which fail to parse keep their full content.
+func TestContentMapKeepsUnparsableFiles(t *testing.T) {
+	fileContentMap := map[string]string{"broken.go": "package broken\nfunc {"}
+
+	result := outline.ContentMap(fileContentMap)
+
+	if result["broken.go"] != fileContentMap["broken.go"] {
+		t.Errorf("expected unparsable file to keep its content, got %s", result["broken.go"])
+	}
+}
+
+const pythonSource = `"""Users module."""
+import os
+
+
+@dataclass
+class User:
+    """A user."""
+    name: str
+
+    def greet(self, other):
+        """Greets another user."""
+        message = """
+def not_a_function():
+"""
+        return message
+
+
+def main():
+    print(os.getcwd())
+`
+
+// Python source with "#" and brackets inside string literals.
+const pythonStringsSource = `class Splitter:
+    DEFAULTS = {"sep": "#", "open": "("}
+
+    def split(self, s="#"):  # "#" is the default
+        return s.split(self.DEFAULTS["sep"])
+
+
+def join(parts, sep=')'):
+    return sep.join(parts)
+`
+
+// Tests that "#" and brackets in strings do not end the outline of a Python file.
+func TestContentMapOutlinesPythonStrings(t *testing.T) {
+	result := outline.ContentMap(map[string]string{"split.py": pythonStringsSource})
+
+	expected := `class Splitter:
+    DEFAULTS = {"sep": "#", "open": "("}
+
+    def split(self, s="#"):  # "#" is the default
+        ...
+
+def join(parts, sep=')'):
+    ...
+`
+	if result["split.py"] != expected {
+		t.Errorf("expected \n%s\n, got \n%s\n", expected, result["split.py"])
+	}
+}
+
+// Tests that Python files keep classes and signatures while function bodies are elided.
+func TestContentMapOutlinesPythonFiles(t *testing.T) {
+	result := outline.ContentMap(map[string]string{"users.py": pythonSource})
+
+	expected := `"""Users module."""
+import os
+
+@dataclass
+class User:
+    """A user."""
+    name: str
+
+    def greet(self, other):
+        """Greets another user."""
+        ...
+
+def main():
+    ...
+`
+	if result["users.py"] != expected {
+		t.Errorf("expected \n%s\n, got \n%s\n", expected, result["users.py"])
+	}
+}
+
+const typeScriptSource = `import { Client } from "./client";
+
+/** Options of the service. */
+export interface Options {
+  retries: number;
+}
+
+const cache = new Map<string, string>();
+
+export async function load(id: string): Promise<string> {
+  if (cache.has(id)) {
+    return "}";
+  }
+  return "";
+}
+
+export class Service {
+  private client: Client;
+
+  constructor(client: Client) {
+    this.client = client;
+  }
+}
+`
+
+// Tests that TypeScript files keep imports, exports and class members while bodies are elided.
+func TestContentMapOutlinesTypeScriptFiles(t *testing.T) {
+	result := outline.ContentMap(map[string]string{"service.ts": typeScriptSource})
+
+	expected := `import { Client } from "./client";
+
+/** Options of the service. */
+export interface Options {
+  retries: number;
+}
+
+export async function load(id: string): Promise<string> { ... }
+
+export class Service {
+  private client: Client;
+
+  constructor(client: Client) { ... }
+}
+`
+	if result["service.ts"] != expected {
+		t.Errorf("expected \n%s\n, got \n%s\n", expected, result["service.ts"])
+	}
+}
+
+// Tests that outliners registered for new extensions are used.
+func TestRegisterOutliner(t *testing.T) {
+	outline.Register(".rs", outline.OutlinerFunc(func(_ string, content string) (string, error) {
+		return "outlined", nil
+	}))
+
+	result := outline.ContentMap(map[string]string{"main.rs": "fn main() {}"})
+
+	if result["main.rs"] != "outlined" {
+		t.Errorf("expected registered outliner to be used, got %s", result["main.rs"])
+	}
+}
@@ -0,0 +1,45 @@
+package redaction_test
+
+import (
+	"testing"
+
+	"github.com/vossenwout/crev/internal/redaction"
+)
+
+// Tests that the same value is replaced by the same placeholder across files.
+func TestRedactIsDeterministic(t *testing.T) {
+	redactor, err := redaction.NewRedactor([]string{"email", "ipv4"},
+		[]redaction.Rule{{Name: "customer_id", Pattern: `CUST-[0-9]{6}`}})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	fileContentMap := map[string]string{
+		"a_test.py": `send("alice@example.com", "CUST-123456")`,
+		"b_test.py": `send("bob@example.com", "alice@example.com", "10.0.0.1")`,
+	}
+
+	redacted, counts := redactor.Redact(fileContentMap)
+
+	expected := map[string]string{
+		"a_test.py": `send("<EMAIL_1>", "<CUSTOMER_ID_1>")`,
+		"b_test.py": `send("<EMAIL_2>", "<EMAIL_1>", "<IPV4_1>")`,
+	}
+	for path, content := range expected {
+		if redacted[path] != content {
+			t.Errorf("expected %s for %s, got %s", content, path, redacted[path])
+		}
+	}
+	if counts["email"] != 3 || counts["customer_id"] != 1 || counts["ipv4"] != 1 {
+		t.Errorf("unexpected redaction counts %v", counts)
+	}
+}
+
+// Tests that unknown built-in rules and invalid patterns are rejected.
+func TestNewRedactorRejectsInvalidRules(t *testing.T) {
+	if _, err := redaction.NewRedactor([]string{"passport"}, nil); err == nil {
+		t.Errorf("expected error for unknown rule")
+	}
+	if _, err := redaction.NewRedactor(nil, []redaction.Rule{{Name: "broken", Pattern: "("}}); err == nil {
+		t.Errorf("expected error for invalid pattern")
+	}
+}
@@ -0,0 +1,27 @@
+package review_test
+
+import (
+	"testing"
+
+	"github.com/vossenwout/crev/internal/cache"
+	reviewcli "github.com/vossenwout/crev/internal/review"
+)
+
+// Tests that only reviews of the same code and instructions by the same provider are cached.
+func TestCached(t *testing.T) {
+	c := cache.New(t.TempDir())
+	provider := fixedProvider{}
+	if err := c.Put(reviewcli.CacheKey("package main\n", provider, ""), map[string]string{"review": "Looks good.\n"}); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if !reviewcli.Cached(c, "package main\n", provider, "") {
+		t.Error("expected the review to be cached")
+	}
+	if reviewcli.Cached(c, "package other\n", provider, "") || reviewcli.Cached(c, "package main\n", provider, "be brief") {
+		t.Error("expected other code and instructions not to be cached")
+	}
+	if reviewcli.Cached(nil, "package main\n", provider, "") {
+		t.Error("expected nothing to be cached without a cache")
+	}
+}
@@ -0,0 +1,219 @@
+package review_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/vossenwout/crev/internal/formatting"
+	"github.com/vossenwout/crev/pkg/review"
+)
+
+// Creates a bundle of the files, each with content of the given size.
+func createBundle(paths []string, size int) string {
+	fileContentMap := make(map[string]string, len(paths))
+	for _, path := range paths {
+		fileContentMap[path] = strings.Repeat("x", size)
+	}
+	return formatting.CreateProjectString(formatting.GeneratePathTree(formatting.TreePaths(paths)), fileContentMap)
+}
+
+// Tests that bundles are split by directory within the token budget.
+func TestSplitBundle(t *testing.T) {
+	// Every file is 100 tokens by the upper estimate.
+	bundle := createBundle([]string{"a/1.go", "a/2.go", "b/1.go", "b/2.go", "b/3.go", "c/1.go"}, 300)
+
+	chunks := review.SplitBundle(bundle, 250)
+
+	expected := [][]string{{"a/1.go", "a/2.go"}, {"b/1.go", "b/2.go"}, {"b/3.go", "c/1.go"}}
+	if len(chunks) != len(expected) {
+		t.Fatalf("expected %d chunks, got %d: %+v", len(expected), len(chunks), chunks)
+	}
+	for i, chunk := range chunks {
+		if strings.Join(chunk.Files, ",") != strings.Join(expected[i], ",") {
+			t.Errorf("expected chunk %d to have %v, got %v", i+1, expected[i], chunk.Files)
+		}
+		if !strings.HasPrefix(chunk.Bundle, fmt.Sprintf("This is part %d of 3", i+1)) {
+			t.Errorf("expected chunk %d to say which part it is, got %q", i+1, chunk.Bundle[:40])
+		}
+		if len(formatting.ParseProjectString(chunk.Bundle)) != len(expected[i]) {
+			t.Errorf("expected chunk %d to contain its files, got %q", i+1, chunk.Bundle)
+		}
+	}
+
+	if chunks := review.SplitBundle(bundle, 10); len(chunks) != 6 {
+		t.Errorf("expected files larger than the budget to get a chunk each, got %d chunks", len(chunks))
+	}
+}
+
+// Tests that the framing before the files of a bundle starts every chunk.
+func TestSplitBundleKeepsFraming(t *testing.T) {
+	framing := "This is not a complete project, it is a change to a project (compared to HEAD).\n\n"
+	bundle := framing + createBundle([]string{"a/1.go", "b/1.go"}, 300)
+
+	chunks := review.SplitBundle(bundle, 150)
+
+	if len(chunks) != 2 {
+		t.Fatalf("expected 2 chunks, got %d: %+v", len(chunks), chunks)
+	}
+	for i, chunk := range chunks {
+		if !strings.HasPrefix(chunk.Bundle, framing+fmt.Sprintf("This is part %d of 2", i+1)) {
+			t.Errorf("expected chunk %d to start with the framing, got %q", i+1, chunk.Bundle)
+		}
+		if len(formatting.ParseProjectString(chunk.Bundle)) != 1 {
+			t.Errorf("expected chunk %d to contain its file, got %q", i+1, chunk.Bundle)
+		}
+	}
+}
+
+// Provider that reports a finding for the first file of every chunk it reviews.
+type chunkProvider struct {
+	running, maxRunning atomic.Int32
+	mu                  sync.Mutex
+	reviewed            int
+}
+
+func (p *chunkProvider) Name() string { return "chunks" }
+
+func (p *chunkProvider) Destination() string { return "nowhere" }
+
+func (p *chunkProvider) Review(_ context.Context, code string) (string, error) {
+	running := p.running.Add(1)
+	defer p.running.Add(-1)
+	for {
+		maxRunning := p.maxRunning.Load()
+		if running <= maxRunning || p.maxRunning.CompareAndSwap(maxRunning, running) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	p.mu.Lock()
+	p.reviewed++
+	p.mu.Unlock()
+
+	var paths []string
+	for path := range formatting.ParseProjectString(code) {
+		paths = append(paths, path)
+	}
+	// Every chunk reports the same issue in a.go, which must be deduplicated.
+	return "Review of a part.\n\n```crev-findings\n" +
+		`{"findings": [{"path": "a.go", "start_line": 1, "severity": "high", "category": "bug", "message": "duplicate"},` +
+		`{"path": "` + paths[0] + `", "start_line": 1, "severity": "low", "category": "style", "message": "part"}]}` +
+		"\n```\n", nil
+}
+
+// Tests that large bundles are reviewed in chunks with bounded concurrency.
+func TestReviewChunked(t *testing.T) {
+	paths := []string{"a.go"}
+	for i := 0; i < 11; i++ {
+		paths = append(paths, fmt.Sprintf("dir%d/file.go", i))
+	}
+	bundle := createBundle(paths, 300)
+	provider := &chunkProvider{}
+	var progress []int
+
+	result, err := review.ReviewWith(context.Background(), provider, strings.NewReader(bundle), review.Options{
+		ChunkTokens: 150,
+		Concurrency: 3,
+		OnChunk:     func(done int, total int) { progress = append(progress, done) },
+	})
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result.Chunks != 12 || provider.reviewed != 12 || len(progress) != 12 {
+		t.Errorf("expected 12 chunks to be reviewed and reported, got %d, %d and %v", result.Chunks, provider.reviewed, progress)
+	}
+	if provider.maxRunning.Load() > 3 {
+		t.Errorf("expected at most 3 chunks to be reviewed at the same time, got %d", provider.maxRunning.Load())
+	}
+	if len(result.Findings) != 13 {
+		t.Errorf("expected the duplicate finding to be reported once, got %d findings", len(result.Findings))
+	}
+	if !strings.HasPrefix(result.Review, "# Code review\n\nThe project was reviewed in 12 parts.") ||
+		strings.Contains(result.Review, "crev-findings") {
+		t.Errorf("expected the reviews of the parts to be combined, got %q", result.Review)
+	}
+}
+
+// Tests that chat models combine the reviews of the chunks.
+func TestReviewChunkedSynthesis(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		var body strings.Builder
+		buf := make([]byte, 1024)
+		for {
+			n, err := r.Body.Read(buf)
+			body.Write(buf[:n])
+			if err != nil {
+				break
+			}
+		}
+		content := "part review"
+		if strings.Contains(body.String(), "reviews of all parts") {
+			content = "combined review"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"choices": [{"message": {"role": "assistant", "content": %q}}]}`, content)
+	}))
+	defer server.Close()
+
+	provider, err := review.NewProvider(review.Options{Provider: "openai", BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	bundle := createBundle([]string{"a/1.go", "b/1.go"}, 300)
+	result, err := review.ReviewWith(context.Background(), provider, strings.NewReader(bundle), review.Options{ChunkTokens: 150})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result.Review != "combined review" || requests.Load() != 3 {
+		t.Errorf("expected 2 part reviews and a synthesis, got %q after %d requests", result.Review, requests.Load())
+	}
+}
+
+// Provider that fails to review one chunk.
+type failingChunkProvider struct{ chunkProvider }
+
+func (p *failingChunkProvider) Review(ctx context.Context, code string) (string, error) {
+	if strings.Contains(code, "dir3/") {
+		return "", errors.New("chunk failed")
+	}
+	return p.chunkProvider.Review(ctx, code)
+}
+
+// Tests that the review fails with the error of a failing chunk.
+func TestReviewChunkedError(t *testing.T) {
+	paths := []string{"a.go"}
+	for i := 0; i < 6; i++ {
+		paths = append(paths, fmt.Sprintf("dir%d/file.go", i))
+	}
+	bundle := createBundle(paths, 300)
+	_, err := review.ReviewWith(context.Background(), &failingChunkProvider{}, strings.NewReader(bundle),
+		review.Options{ChunkTokens: 150, Concurrency: 2})
+	if err == nil || !strings.Contains(err.Error(), "chunk failed") {
+		t.Errorf("expected the error of the failing chunk, got %v", err)
+	}
+}
+
+// Tests that findings about the same lines and category are only kept once.
+func TestDeduplicateFindings(t *testing.T) {
+	findings := []review.Finding{
+		{Path: "a.go", StartLine: 1, EndLine: 5, Severity: review.SeverityLow, Category: "bug", Message: "low"},
+		{Path: "a.go", StartLine: 4, EndLine: 8, Severity: review.SeverityHigh, Category: "Bug", Message: "high"},
+		{Path: "a.go", StartLine: 4, EndLine: 4, Severity: review.SeverityLow, Category: "style", Message: "style"},
+		{Path: "a.go", StartLine: 9, EndLine: 9, Severity: review.SeverityLow, Category: "bug", Message: "other lines"},
+	}
+	result := review.DeduplicateFindings(findings)
+	if len(result) != 3 || result[0].Message != "high" {
+		t.Errorf("expected the most serious of the overlapping findings to be kept, got %+v", result)
+	}
+}
@@ -0,0 +1,203 @@
+package review_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/vossenwout/crev/internal/formatting"
+	reviewcli "github.com/vossenwout/crev/internal/review"
+	"github.com/vossenwout/crev/pkg/review"
+)
+
+// Provider that streams a fixed review in small chunks.
+type fixedProvider struct {
+	review string
+}
+
+func (p fixedProvider) Name() string { return "fixed" }
+
+func (p fixedProvider) Destination() string { return "nowhere" }
+
+func (p fixedProvider) Review(context.Context, string) (string, error) { return p.review, nil }
+
+func (p fixedProvider) ReviewStream(_ context.Context, _ string, w io.Writer) (string, error) {
+	for i := 0; i < len(p.review); i += 5 {
+		if _, err := io.WriteString(w, p.review[i:min(i+5, len(p.review))]); err != nil {
+			return "", err
+		}
+	}
+	return p.review, nil
+}
+
+// Tests that the findings block is split from the markdown of a review.
+func TestParseFindings(t *testing.T) {
+	text := "# Review\n\nLooks good.\n\n```crev-findings\n" +
+		`{"findings": [{"path": "main.go", "start_line": 2, "end_line": 3, "severity": "high", "category": "bug", "message": "m", "suggestion": "s"}]}` +
+		"\n```\n"
+	markdown, findings, err := review.ParseFindings(text)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if markdown != "# Review\n\nLooks good.\n" {
+		t.Errorf("expected the markdown without the block, got %q", markdown)
+	}
+	expected := review.Finding{Path: "main.go", StartLine: 2, EndLine: 3, Severity: review.SeverityHigh,
+		Category: "bug", Message: "m", Suggestion: "s"}
+	if len(findings) != 1 || findings[0] != expected {
+		t.Errorf("expected %+v, got %+v", expected, findings)
+	}
+
+	markdown, findings, err = review.ParseFindings("# Review\n")
+	if err != nil || markdown != "# Review\n" || findings != nil {
+		t.Errorf("expected a review without findings, got %q %v %v", markdown, findings, err)
+	}
+
+	_, _, err = review.ParseFindings("# Review\n```crev-findings\n{not json\n```\n")
+	if err == nil {
+		t.Error("expected an error for an invalid block")
+	}
+}
+
+// Tests that findings which do not match the bundle are rejected and others normalized.
+func TestValidateFindings(t *testing.T) {
+	lineCounts := map[string]int{"main.go": 10, "diff.go": 0}
+	findings := []review.Finding{
+		{Path: "main.go", StartLine: 2, Severity: "HIGH", Message: "missing end line"},
+		{Path: "main.go", StartLine: 9, EndLine: 11, Severity: "low", Message: "past the end"},
+		{Path: "main.go", Severity: "info", Message: "whole file"},
+		{Path: "diff.go", StartLine: 500, Severity: "medium", Message: "unknown line count"},
+		{Path: "other.go", StartLine: 1, Severity: "low", Message: "unknown file"},
+		{Path: "main.go", StartLine: 11, Severity: "low", Message: "unknown line"},
+		{Path: "main.go", StartLine: 5, EndLine: 4, Severity: "low", Message: "reversed lines"},
+		{Path: "main.go", StartLine: 1, Severity: "urgent", Message: "unknown severity"},
+		{Path: "main.go", StartLine: 1, Severity: "low"},
+	}
+
+	valid, invalid := review.ValidateFindings(findings, lineCounts)
+
+	if len(valid) != 4 || len(invalid) != 5 {
+		t.Fatalf("expected 4 valid and 5 invalid findings, got %+v and %+v", valid, invalid)
+	}
+	if valid[0].Severity != review.SeverityHigh || valid[0].EndLine != 2 {
+		t.Errorf("expected severity and end line to be normalized, got %+v", valid[0])
+	}
+	if valid[1].EndLine != 10 {
+		t.Errorf("expected end line to be clamped to the file, got %+v", valid[1])
+	}
+	if invalid[0].Finding.Path != "other.go" || invalid[0].Reason != "file other.go is not part of the bundle" {
+		t.Errorf("expected the unknown file to be rejected, got %+v", invalid[0])
+	}
+}
+
+// Tests that reviews return validated findings and hide the block from the stream.
+func TestReviewFindings(t *testing.T) {
+	bundle := formatting.CreateProjectString("main.go\n", map[string]string{"main.go": "package main\n\nfunc main() {}"})
+	text := "Looks good.\n\n```crev-findings\n" +
+		`{"findings": [{"path": "main.go", "start_line": 3, "severity": "low", "category": "style", "message": "empty main"},` +
+		`{"path": "missing.go", "start_line": 1, "severity": "low", "category": "bug", "message": "not bundled"}]}` +
+		"\n```\n"
+
+	var out strings.Builder
+	result, err := review.ReviewWith(context.Background(), fixedProvider{review: text}, strings.NewReader(bundle),
+		review.Options{Stream: &out})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if out.String() != "Looks good." {
+		t.Errorf("expected the block to be hidden from the stream, got %q", out.String())
+	}
+	if result.Review != "Looks good.\n" {
+		t.Errorf("expected the review without the block, got %q", result.Review)
+	}
+	if len(result.Findings) != 1 || result.Findings[0].StartLine != 3 || len(result.InvalidFindings) != 1 {
+		t.Errorf("expected one valid and one invalid finding, got %+v and %+v", result.Findings, result.InvalidFindings)
+	}
+}
+
+// Tests that a trailing newline does not count as another line.
+func TestLineCounts(t *testing.T) {
+	bundle := formatting.CreateProjectString("a.go\nb.go\nc.go\n", map[string]string{
+		"a.go": "package a\n\nfunc A() {}\n",
+		"b.go": "package b\n\nfunc B() {}",
+		"c.go": "",
+	})
+
+	lineCounts := review.LineCounts(bundle)
+
+	expected := map[string]int{"a.go": 3, "b.go": 3, "c.go": 0}
+	for path, lines := range expected {
+		if lineCounts[path] != lines {
+			t.Errorf("expected %s to have %d lines, got %d", path, lines, lineCounts[path])
+		}
+	}
+}
+
+// Tests that severities compare by seriousness.
+func TestSeverityAtLeast(t *testing.T) {
+	if !review.SeverityCritical.AtLeast(review.SeverityHigh) || !review.SeverityHigh.AtLeast(review.SeverityHigh) {
+		t.Error("expected critical and high to meet a high threshold")
+	}
+	if review.SeverityMedium.AtLeast(review.SeverityHigh) {
+		t.Error("expected medium not to meet a high threshold")
+	}
+	if _, err := review.ParseSeverity("urgent"); err == nil {
+		t.Error("expected an error for an unknown severity")
+	}
+}
+
+// Tests that findings are sorted by seriousness and summarized one per line.
+func TestFindingsSummary(t *testing.T) {
+	findings := []review.Finding{
+		{Path: "b.go", StartLine: 4, EndLine: 4, Severity: review.SeverityLow, Category: "style", Message: "long\nline"},
+		{Path: "b.go", StartLine: 1, EndLine: 3, Severity: review.SeverityCritical, Category: "security", Message: "injection"},
+		{Path: "a.go", Severity: review.SeverityLow, Message: "too long"},
+	}
+
+	review.SortFindings(findings)
+	summary := reviewcli.Summary(findings)
+
+	expected := "b.go:1-3: critical [security] injection\n" +
+		"a.go: low too long\n" +
+		"b.go:4: low [style] long line\n"
+	if summary != expected {
+		t.Errorf("expected summary %q, got %q", expected, summary)
+	}
+	if failing := reviewcli.FailingFindings(findings, review.SeverityHigh); failing != 1 {
+		t.Errorf("expected 1 finding to fail a high threshold, got %d", failing)
+	}
+	if failing := reviewcli.FailingFindings(findings, ""); failing != 0 {
+		t.Errorf("expected no findings to fail without threshold, got %d", failing)
+	}
+}
+
+// Tests that a review fails on findings when they are missing or cannot be parsed.
+func TestUnknownFindings(t *testing.T) {
+	bundle := formatting.CreateProjectString("main.go\n", map[string]string{"main.go": "package main\n"})
+	reviews := map[string]string{
+		"missing":     "Looks good.\n",
+		"unparseable": "Looks good.\n\n```crev-findings\n{\"findings\": [\n```\n",
+	}
+	for name, text := range reviews {
+		result, err := review.ReviewWith(context.Background(), fixedProvider{review: text}, strings.NewReader(bundle), review.Options{})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if err := reviewcli.UnknownFindings(result, review.SeverityHigh); err == nil {
+			t.Errorf("expected the %s findings to fail a high threshold", name)
+		}
+		if err := reviewcli.UnknownFindings(result, ""); err != nil {
+			t.Errorf("expected the %s findings not to fail without threshold, got %v", name, err)
+		}
+	}
+
+	result, err := review.ReviewWith(context.Background(), fixedProvider{review: "Looks good.\n\n" + review.FormatFindings(nil)},
+		strings.NewReader(bundle), review.Options{})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := reviewcli.UnknownFindings(result, review.SeverityHigh); err != nil {
+		t.Errorf("expected an empty findings block to pass, got %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+package review_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vossenwout/crev/internal/formatting"
+	"github.com/vossenwout/crev/internal/history"
+	reviewcli "github.com/vossenwout/crev/internal/review"
+	"github.com/vossenwout/crev/pkg/review"
+)
+
+// Tests that the history keeps the findings that are accepted in the baseline.
+func TestReviewHistoryKeepsBaselinedFindings(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	bundle := formatting.CreateProjectString("main.go\n", map[string]string{"main.go": "package main\n\nfunc main() {}"})
+	text := "Looks good.\n\n" + review.FormatFindings([]review.Finding{
+		{Path: "main.go", StartLine: 3, EndLine: 3, Severity: review.SeverityLow, Category: "style", Message: "empty main"},
+	})
+	h := history.New(filepath.Join(dir, history.DefaultDir))
+
+	reviewcli.Review(bundle, fixedProvider{review: text}, reviewcli.Options{
+		SkipConfirmation: true,
+		Baseline:         filepath.Join(dir, "baseline.json"),
+		WriteBaseline:    true,
+		History:          h,
+	})
+
+	runs, err := h.List()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(runs) != 1 || len(runs[0].Findings) != 1 || runs[0].Findings[0].Fingerprint == "" {
+		t.Errorf("expected the run to keep the fingerprinted finding, got %+v", runs)
+	}
+}
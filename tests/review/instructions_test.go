@@ -0,0 +1,89 @@
+package review_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	reviewcli "github.com/vossenwout/crev/internal/review"
+	"github.com/vossenwout/crev/pkg/review"
+)
+
+// Tests that the instructions of the options are added to the system prompt of reviews.
+func TestProviderSendsInstructions(t *testing.T) {
+	var system string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []review.Message `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("expected JSON body, got %v", err)
+		}
+		if len(body.Messages) > 0 && body.Messages[0].Role == "system" {
+			system = body.Messages[0].Content
+		}
+		_, _ = w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "looks good"}}]}`))
+	}))
+	defer server.Close()
+
+	provider, err := review.NewProvider(review.Options{
+		Provider:     "openai",
+		BaseURL:      server.URL,
+		APIKey:       "key",
+		Instructions: "All HTTP handlers must take a context.",
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := provider.Review(context.Background(), "package main"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if !strings.Contains(system, "All HTTP handlers must take a context.") {
+		t.Errorf("expected the instructions in the system prompt, got %q", system)
+	}
+}
+
+// Tests that focus presets are found case insensitively and unknown ones are rejected.
+func TestFocus(t *testing.T) {
+	for _, name := range review.FocusNames() {
+		if _, err := review.Focus(strings.ToUpper(name)); err != nil {
+			t.Errorf("expected focus %s to exist, got %v", name, err)
+		}
+	}
+	if _, err := review.Focus("style"); err == nil {
+		t.Errorf("expected an error for an unknown focus")
+	}
+}
+
+// Tests that the rules file, the configured instructions and the focus presets are combined.
+func TestInstructions(t *testing.T) {
+	rulesFile := filepath.Join(t.TempDir(), reviewcli.RulesFile)
+	if err := os.WriteFile(rulesFile, []byte("Use contexts.\n"), 0644); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	instructions, sources, err := reviewcli.Instructions(rulesFile, "Wrap errors.", []string{"security"})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	security, _ := review.Focus("security")
+	expected := "Use contexts.\n\nWrap errors.\n\n" + security
+	if instructions != expected {
+		t.Errorf("expected instructions %q, got %q", expected, instructions)
+	}
+	if len(sources) != 3 {
+		t.Errorf("expected 3 sources, got %v", sources)
+	}
+
+	instructions, sources, err = reviewcli.Instructions(filepath.Join(t.TempDir(), "missing.md"), "", nil)
+	if err != nil || instructions != "" || len(sources) != 0 {
+		t.Errorf("expected no instructions without rules, got %q %v %v", instructions, sources, err)
+	}
+	if _, _, err := reviewcli.Instructions(rulesFile, "", []string{"style"}); err == nil {
+		t.Errorf("expected an error for an unknown focus")
+	}
+}
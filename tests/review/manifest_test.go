@@ -0,0 +1,45 @@
+package review_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vossenwout/crev/internal/formatting"
+	"github.com/vossenwout/crev/internal/review"
+)
+
+// Tests that the manifest counts the files of a bundle and flags sensitive ones.
+func TestNewManifest(t *testing.T) {
+	fileContentMap := map[string]string{
+		"main.go":          "package main\n",
+		"config/prod.env":  "PASSWORD=hunter2\n",
+		"certs/server.pem": "certificate\n",
+	}
+	projectString := formatting.CreateProjectString("tree\n", fileContentMap)
+
+	manifest := review.NewManifest(projectString, "https://example.com")
+
+	if manifest.Files != 3 {
+		t.Errorf("expected 3 files, got %d", manifest.Files)
+	}
+	if manifest.Size != len(projectString) {
+		t.Errorf("expected size %d, got %d", len(projectString), manifest.Size)
+	}
+	expectedSensitive := []string{"certs/server.pem", "config/prod.env"}
+	if strings.Join(manifest.SensitiveFiles, ",") != strings.Join(expectedSensitive, ",") {
+		t.Errorf("expected sensitive files %v, got %v", expectedSensitive, manifest.SensitiveFiles)
+	}
+	if !strings.Contains(manifest.String(), "https://example.com") {
+		t.Errorf("expected manifest to show the destination, got \n%s\n", manifest.String())
+	}
+}
+
+// Tests that only an explicit yes confirms.
+func TestConfirm(t *testing.T) {
+	for answer, expected := range map[string]bool{"y\n": true, "YES\n": true, "n\n": false, "\n": false, "": false} {
+		var out strings.Builder
+		if result := review.Confirm("Continue?", strings.NewReader(answer), &out); result != expected {
+			t.Errorf("expected %v for answer %q, got %v", expected, answer, result)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package review_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vossenwout/crev/pkg/review"
+)
+
+// Tests that every provider sends the code to its endpoint and extracts the review.
+func TestProviders(t *testing.T) {
+	tests := []struct {
+		provider string
+		path     string
+		header   string
+		response string
+	}{
+		{provider: "crev", path: "/", header: "Api-Key", response: `{"review": "looks good"}`},
+		{provider: "openai", path: "/chat/completions", header: "Authorization",
+			response: `{"choices": [{"message": {"role": "assistant", "content": "looks good"}}]}`},
+		{provider: "anthropic", path: "/v1/messages", header: "X-Api-Key",
+			response: `{"content": [{"type": "text", "text": "looks good"}]}`},
+		{provider: "ollama", path: "/api/chat", header: "Authorization",
+			response: `{"message": {"role": "assistant", "content": "looks good"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != tt.path {
+					t.Errorf("expected request to %s, got %s", tt.path, r.URL.Path)
+				}
+				if r.Header.Get(tt.header) == "" {
+					t.Errorf("expected header %s to be set", tt.header)
+				}
+				var body map[string]any
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Errorf("expected JSON body, got %v", err)
+				}
+				_, _ = w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			provider, err := review.NewProvider(review.Options{Provider: tt.provider, BaseURL: server.URL, APIKey: "key"})
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			result, err := provider.Review(context.Background(), "package main")
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if result != "looks good" {
+				t.Errorf("expected review %q, got %q", "looks good", result)
+			}
+		})
+	}
+}
+
+// Tests that error responses are returned as status errors.
+func TestProviderStatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid api key", http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	provider, err := review.NewProvider(review.Options{BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	_, err = provider.Review(context.Background(), "package main")
+
+	var statusErr *review.StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected status error with code 401, got %v", err)
+	}
+}
+
+// Tests that an error reported in the body of a successful Ollama response is returned.
+func TestOllamaResponseError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"error": "model \"missing\" not found, try pulling it first"}`))
+	}))
+	defer server.Close()
+
+	provider, err := review.NewProvider(review.Options{Provider: "ollama", BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	result, err := provider.Review(context.Background(), "package main")
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected the error of the response, got %q and %v", result, err)
+	}
+}
+
+// Tests that unknown providers are rejected.
+func TestNewProviderUnknown(t *testing.T) {
+	if _, err := review.NewProvider(review.Options{Provider: "unknown"}); err == nil {
+		t.Errorf("expected error for unknown provider")
+	}
+}
+
+// Tests that the configured headers are added to every request.
+func TestProviderHTTPHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Team") != "platform" {
+			t.Errorf("expected header X-Team to be platform, got %q", r.Header.Get("X-Team"))
+		}
+		_, _ = w.Write([]byte(`{"review": "looks good"}`))
+	}))
+	defer server.Close()
+
+	provider, err := review.NewProvider(review.Options{
+		BaseURL: server.URL,
+		HTTP:    review.HTTPOptions{Headers: map[string]string{"x-team": "platform"}},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := provider.Review(context.Background(), "package main"); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
+
+// Tests that invalid HTTP options are reported when creating the provider.
+func TestProviderInvalidHTTPOptions(t *testing.T) {
+	if _, err := review.NewProvider(review.Options{HTTP: review.HTTPOptions{CACert: "missing.pem"}}); err == nil {
+		t.Errorf("expected error for missing CA bundle")
+	}
+	if _, err := review.NewProvider(review.Options{HTTP: review.HTTPOptions{Proxy: "://proxy"}}); err == nil {
+		t.Errorf("expected error for invalid proxy")
+	}
+}
+
+// Tests that transient failures are retried until the request succeeds.
+func TestProviderRetriesTransientErrors(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"review": "looks good"}`))
+	}))
+	defer server.Close()
+
+	provider, err := review.NewProvider(review.Options{
+		BaseURL: server.URL,
+		HTTP:    review.HTTPOptions{MaxAttempts: 3, RetryDelay: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	result, err := provider.Review(context.Background(), "package main")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if result != "looks good" || attempts != 3 {
+		t.Errorf("expected review after 3 attempts, got %q after %d attempts", result, attempts)
+	}
+}
+
+// Tests that retry delays double up to the cap, also after many attempts.
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		base     time.Duration
+		attempt  int
+		min, max time.Duration
+	}{
+		{base: time.Second, attempt: 1, min: 500 * time.Millisecond, max: time.Second},
+		{base: time.Second, attempt: 3, min: 2 * time.Second, max: 4 * time.Second},
+		{base: time.Second, attempt: 100, min: 15 * time.Second, max: 30 * time.Second},
+		{base: time.Hour, attempt: 2, min: 15 * time.Second, max: 30 * time.Second},
+	}
+	for _, test := range tests {
+		delay := review.Backoff(test.base, test.attempt)
+		if delay < test.min || delay > test.max {
+			t.Errorf("expected a delay between %v and %v for attempt %d, got %v", test.min, test.max, test.attempt, delay)
+		}
+	}
+}
+
+// Tests that failures are returned as typed errors and that only transient ones are retried.
+func TestProviderTypedErrors(t *testing.T) {
+	tests := []struct {
+		status   int
+		body     string
+		attempts int
+		expected error
+	}{
+		{status: http.StatusUnauthorized, body: "invalid key", attempts: 1, expected: review.ErrUnauthorized},
+		{status: http.StatusTooManyRequests, body: "insufficient_quota", attempts: 1, expected: review.ErrQuota},
+		{status: http.StatusRequestEntityTooLarge, body: "too large", attempts: 1, expected: review.ErrTooLarge},
+		{status: http.StatusBadRequest, body: "context_length_exceeded", attempts: 1, expected: review.ErrTooLarge},
+		{status: http.StatusTooManyRequests, body: "slow down", attempts: 2},
+	}
+	for _, tt := range tests {
+		attempts := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, tt.body, tt.status)
+		}))
+
+		_, err := review.Review(context.Background(), strings.NewReader("package main"), review.Options{
+			BaseURL: server.URL,
+			HTTP:    review.HTTPOptions{MaxAttempts: 2, RetryDelay: time.Millisecond},
+		})
+		server.Close()
+
+		if tt.expected != nil && !errors.Is(err, tt.expected) {
+			t.Errorf("expected %v for status %d, got %v", tt.expected, tt.status, err)
+		}
+		if tt.expected == nil && !review.IsTemporary(err) {
+			t.Errorf("expected temporary error for status %d, got %v", tt.status, err)
+		}
+		if attempts != tt.attempts {
+			t.Errorf("expected %d attempts for status %d, got %d", tt.attempts, tt.status, attempts)
+		}
+	}
+}
+
+// Tests that network failures are only retried when they may pass.
+func TestProviderNetworkErrors(t *testing.T) {
+	// Nothing listens on a closed server, so connections are refused.
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	refusedURL := server.URL
+	server.Close()
+
+	tests := []struct {
+		baseURL   string
+		temporary bool
+	}{
+		{baseURL: "htp://bad", temporary: false},
+		{baseURL: refusedURL, temporary: true},
+	}
+	for _, tt := range tests {
+		retries := 0
+		_, err := review.Review(context.Background(), strings.NewReader("package main"), review.Options{
+			BaseURL: tt.baseURL,
+			HTTP: review.HTTPOptions{MaxAttempts: 2, RetryDelay: time.Millisecond,
+				OnRetry: func(error, time.Duration, int) { retries++ }},
+		})
+		if err == nil {
+			t.Fatalf("expected an error for %s", tt.baseURL)
+		}
+		if review.IsTemporary(err) != tt.temporary {
+			t.Errorf("expected temporary to be %v for %s, got %v", tt.temporary, tt.baseURL, err)
+		}
+		if tt.temporary != (retries == 1) {
+			t.Errorf("expected %s to be retried only if temporary, got %d retries", tt.baseURL, retries)
+		}
+	}
+}
+
+// Tests that bundles above the maximum size are rejected before anything is sent.
+func TestReviewMaxBundleSize(t *testing.T) {
+	_, err := review.Review(context.Background(), strings.NewReader("package main"), review.Options{
+		BaseURL:       "http://127.0.0.1:0",
+		MaxBundleSize: 4,
+	})
+	if !errors.Is(err, review.ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+}
+
+// Tests that a cancelled context stops the review.
+func TestReviewCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(200 * time.Millisecond):
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := review.Review(ctx, strings.NewReader("package main"), review.Options{BaseURL: server.URL})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded error, got %v", err)
+	}
+}
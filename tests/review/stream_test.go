@@ -0,0 +1,86 @@
+package review_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vossenwout/crev/pkg/review"
+)
+
+// Tests that streamed reviews are written while they arrive and returned completely.
+func TestProvidersStream(t *testing.T) {
+	tests := []struct {
+		provider    string
+		contentType string
+		stream      string
+	}{
+		{provider: "crev", contentType: "text/event-stream",
+			stream: "data: {\"review\": \"looks \"}\n\ndata: {\"review\": \"good\"}\n\nevent: done\ndata: {}\n\n"},
+		{provider: "crev", contentType: "application/json", stream: `{"review": "looks good"}`},
+		{provider: "openai", contentType: "text/event-stream",
+			stream: "data: {\"choices\": [{\"delta\": {\"content\": \"looks \"}}]}\n\n" +
+				"data: {\"choices\": [{\"delta\": {\"content\": \"good\"}}]}\n\ndata: [DONE]\n\n"},
+		{provider: "anthropic", contentType: "text/event-stream",
+			stream: "event: message_start\ndata: {}\n\n" +
+				"event: content_block_delta\ndata: {\"delta\": {\"type\": \"text_delta\", \"text\": \"looks \"}}\n\n" +
+				": keep-alive\n\n" +
+				"event: content_block_delta\ndata: {\"delta\": {\"type\": \"text_delta\", \"text\": \"good\"}}\n\n" +
+				"event: message_stop\ndata: {}\n\n"},
+		{provider: "ollama", contentType: "application/x-ndjson",
+			stream: "{\"message\": {\"content\": \"looks \"}, \"done\": false}\n" +
+				"{\"message\": {\"content\": \"good\"}, \"done\": true}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider+" "+tt.contentType, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				_, _ = w.Write([]byte(tt.stream))
+			}))
+			defer server.Close()
+
+			provider, err := review.NewProvider(review.Options{Provider: tt.provider, BaseURL: server.URL, APIKey: "key"})
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			streamer, ok := provider.(review.StreamingProvider)
+			if !ok {
+				t.Fatalf("expected provider %s to support streaming", tt.provider)
+			}
+			var out strings.Builder
+			result, err := streamer.ReviewStream(context.Background(), "package main", &out)
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if result != "looks good" || out.String() != "looks good" {
+				t.Errorf("expected review %q to be returned and written, got %q and %q", "looks good", result, out.String())
+			}
+		})
+	}
+}
+
+// Tests that a stream which breaks off returns the partial review and an interruption error.
+func TestProviderStreamInterrupted(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: {\"choices\": [{\"delta\": {\"content\": \"looks \"}}]}\n\n"))
+	}))
+	defer server.Close()
+
+	provider, err := review.NewProvider(review.Options{Provider: "openai", BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	var out strings.Builder
+	result, err := provider.(review.StreamingProvider).ReviewStream(context.Background(), "package main", &out)
+
+	if !errors.Is(err, review.ErrStreamInterrupted) {
+		t.Errorf("expected stream interrupted error, got %v", err)
+	}
+	if result != "looks " {
+		t.Errorf("expected partial review %q, got %q", "looks ", result)
+	}
+}
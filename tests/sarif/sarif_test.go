@@ -0,0 +1,107 @@
+package sarif_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/vossenwout/crev/internal/sarif"
+	"github.com/vossenwout/crev/pkg/review"
+)
+
+// Tests that findings are converted to results with rules, locations and fingerprints.
+func TestFromFindings(t *testing.T) {
+	findings := []review.Finding{
+		{Path: "cmd/main.go", StartLine: 3, EndLine: 5, Severity: review.SeverityHigh, Category: "security",
+			Message: "SQL injection", Suggestion: "Use a prepared statement."},
+		{Path: "util.go", Severity: review.SeverityLow, Category: "style", Message: "File is too long"},
+		{Path: "cmd/main.go", StartLine: 9, EndLine: 9, Severity: review.SeverityMedium, Category: "security",
+			Message: "Token is logged"},
+	}
+
+	log := sarif.FromFindings(findings, nil, sarif.Options{ToolVersion: "1.2.3", PathPrefix: "services/api/"})
+
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("expected a SARIF 2.1.0 log with one run, got %+v", log)
+	}
+	run := log.Runs[0]
+	if run.Tool.Driver.Name != "crev" || run.Tool.Driver.Version != "1.2.3" {
+		t.Errorf("expected the crev driver, got %+v", run.Tool.Driver)
+	}
+	if len(run.Tool.Driver.Rules) != 2 || run.Tool.Driver.Rules[0].ID != "crev/security" || run.Tool.Driver.Rules[1].ID != "crev/style" {
+		t.Errorf("expected a rule per category, got %+v", run.Tool.Driver.Rules)
+	}
+	if len(run.Results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(run.Results))
+	}
+
+	first := run.Results[0]
+	if first.RuleID != "crev/security" || first.RuleIndex != 0 || first.Level != "error" {
+		t.Errorf("expected an error of the security rule, got %+v", first)
+	}
+	location := first.Locations[0].PhysicalLocation
+	if location.ArtifactLocation.URI != "services/api/cmd/main.go" || location.ArtifactLocation.URIBaseID != "%SRCROOT%" {
+		t.Errorf("expected a repository relative path, got %+v", location.ArtifactLocation)
+	}
+	if location.Region == nil || location.Region.StartLine != 3 || location.Region.EndLine != 5 {
+		t.Errorf("expected lines 3 to 5, got %+v", location.Region)
+	}
+	if first.Message.Text != "SQL injection\n\nSuggestion: Use a prepared statement." {
+		t.Errorf("expected the message with the suggestion, got %q", first.Message.Text)
+	}
+	if first.PartialFingerprints["crevFinding/v1"] == "" {
+		t.Errorf("expected a fingerprint, got %v", first.PartialFingerprints)
+	}
+
+	second := run.Results[1]
+	if second.RuleIndex != 1 || second.Level != "note" || second.Locations[0].PhysicalLocation.Region != nil {
+		t.Errorf("expected a note about the whole file, got %+v", second)
+	}
+	if run.Results[2].PartialFingerprints["crevFinding/v1"] == first.PartialFingerprints["crevFinding/v1"] {
+		t.Error("expected different findings to have different fingerprints")
+	}
+
+	data, err := log.Marshal()
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("expected valid JSON, got %v", err)
+	}
+	if decoded["$schema"] != sarif.Schema {
+		t.Errorf("expected the SARIF schema, got %v", decoded["$schema"])
+	}
+}
+
+// Tests that fingerprints do not depend on line numbers or whitespace.
+func TestFingerprint(t *testing.T) {
+	a := review.Finding{Path: "main.go", StartLine: 3, Category: "bug", Message: "Nil  pointer dereference"}
+	b := review.Finding{Path: "main.go", StartLine: 30, Category: "Bug", Message: "nil pointer dereference"}
+	if sarif.Fingerprint("main.go", a) != sarif.Fingerprint("main.go", b) {
+		t.Error("expected moved findings to keep their fingerprint")
+	}
+	if sarif.Fingerprint("main.go", a) == sarif.Fingerprint("other.go", a) {
+		t.Error("expected findings in other files to have other fingerprints")
+	}
+}
+
+// Tests that suppressed findings are included with an in source suppression.
+func TestFromFindingsSuppressed(t *testing.T) {
+	suppressed := []sarif.Suppressed{{
+		Finding:       review.Finding{Path: "main.go", StartLine: 2, Severity: review.SeverityHigh, Category: "security", Message: "injection"},
+		Justification: "constant query",
+	}}
+
+	log := sarif.FromFindings(nil, suppressed, sarif.Options{})
+
+	results := log.Runs[0].Results
+	if len(results) != 1 || len(results[0].Suppressions) != 1 {
+		t.Fatalf("expected one suppressed result, got %+v", results)
+	}
+	if results[0].Suppressions[0].Kind != "inSource" || results[0].Suppressions[0].Justification != "constant query" {
+		t.Errorf("expected an in source suppression with its justification, got %+v", results[0].Suppressions[0])
+	}
+	if len(log.Runs[0].Tool.Driver.Rules) != 1 {
+		t.Errorf("expected a rule for the suppressed finding, got %+v", log.Runs[0].Tool.Driver.Rules)
+	}
+}
@@ -0,0 +1,99 @@
+package suppress_test
+
+import (
+	"testing"
+
+	"github.com/vossenwout/crev/internal/suppress"
+	"github.com/vossenwout/crev/pkg/review"
+)
+
+const goContent = `package main
+
+// crev:ignore security the query only uses constants
+func query() {
+	db.Exec("SELECT " + column)
+}
+
+func main() {
+	// crev:ignore performance runs once at startup
+	for i := 0; i < 10; i++ {
+		load(i)
+	}
+	debug() // crev:ignore style kept for local debugging
+	run()
+}
+
+func stub() {
+	// crev:ignore maintainability implemented by the generated code
+}
+`
+
+const pythonContent = `def handler(event):
+    # crev:ignore all generated code
+    x = event["x"]
+    return x
+
+def other():
+    # crev:ignore style kept as a hook
+`
+
+// Tests that suppression comments apply to the next line or block, or to the enclosing block
+// when nothing follows them in it.
+func TestParse(t *testing.T) {
+	comments, errs := suppress.Parse("main.go", goContent)
+	if len(errs) != 0 {
+		t.Fatalf("expected no errors, got %v", errs)
+	}
+	expected := []suppress.Comment{
+		{Path: "main.go", Line: 3, Category: "security", Reason: "the query only uses constants", StartLine: 4, EndLine: 6},
+		{Path: "main.go", Line: 9, Category: "performance", Reason: "runs once at startup", StartLine: 10, EndLine: 12},
+		{Path: "main.go", Line: 13, Category: "style", Reason: "kept for local debugging", StartLine: 13, EndLine: 13},
+		{Path: "main.go", Line: 18, Category: "maintainability", Reason: "implemented by the generated code", StartLine: 17, EndLine: 19},
+	}
+	if len(comments) != len(expected) {
+		t.Fatalf("expected %d comments, got %+v", len(expected), comments)
+	}
+	for i := range expected {
+		if comments[i] != expected[i] {
+			t.Errorf("expected %+v, got %+v", expected[i], comments[i])
+		}
+	}
+
+	comments, _ = suppress.Parse("handler.py", pythonContent)
+	if len(comments) != 2 || comments[0].StartLine != 3 || comments[0].EndLine != 3 {
+		t.Errorf("expected the first comment to apply to the next line, got %+v", comments)
+	}
+	if len(comments) == 2 && (comments[1].StartLine != 6 || comments[1].EndLine != 7) {
+		t.Errorf("expected the last comment to apply to the enclosing function, got %+v", comments)
+	}
+
+	_, errs = suppress.Parse("main.go", "// crev:ignore security\nfunc a() {}\n")
+	if len(errs) != 1 {
+		t.Errorf("expected an error for a comment without reason, got %v", errs)
+	}
+}
+
+// Tests that matching findings are suppressed with the reason of their comment.
+func TestApply(t *testing.T) {
+	findings := []review.Finding{
+		{Path: "main.go", StartLine: 5, Severity: review.SeverityHigh, Category: "security", Message: "injection"},
+		{Path: "main.go", StartLine: 5, Severity: review.SeverityLow, Category: "style", Message: "concatenation"},
+		{Path: "main.go", StartLine: 11, Severity: review.SeverityLow, Category: "Performance", Message: "slow loop"},
+		{Path: "main.go", StartLine: 14, Severity: review.SeverityLow, Category: "bug", Message: "run can fail"},
+	}
+
+	kept, suppressed, errs := suppress.Apply(findings, map[string]string{"main.go": goContent})
+
+	if len(errs) != 0 {
+		t.Fatalf("expected no errors, got %v", errs)
+	}
+	if len(kept) != 2 || kept[0].Message != "concatenation" || kept[1].Message != "run can fail" {
+		t.Errorf("expected the style and bug findings to be kept, got %+v", kept)
+	}
+	if len(suppressed) != 2 {
+		t.Fatalf("expected 2 suppressed findings, got %+v", suppressed)
+	}
+	if suppressed[0].Reason != "the query only uses constants" || suppressed[0].Comment != "main.go:3" {
+		t.Errorf("expected the reason and location of the comment, got %+v", suppressed[0])
+	}
+}